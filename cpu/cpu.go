@@ -2,12 +2,17 @@
 package cpu
 
 import (
+	"context"
 	"fmt"
 	"math"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"text/template"
+	"time"
 
+	"github.com/aretaja/check-gosnmp-cpu/state"
 	"github.com/aretaja/icingahelper"
 	"github.com/aretaja/snmphelper"
 	"github.com/kr/pretty"
@@ -18,146 +23,3677 @@ type Load struct {
 	Check             *icingahelper.IcingaCheck
 	Sess              *snmphelper.Session
 	Warn, Crit, Ctype string
+	MoxaSuffix        string        // comma separated moxasw oid suffixes relative to sysObjectID
+	Rate              string        // comma separated busy and total tick counter oids for rate check
+	ApplianceOid      string        // vendor cpu utilization oid of appliance check. Empty means hrProcessorLoad
+	ProbeOrder        string        // comma separated candidate cpu utilization oids of firstof check. hrProcessorLoad means its average
+	Unit              string        // perfdata unit of appliance oid value if UnitSet
+	UnitSet           bool          // Unit is set. Percent is used otherwise
+	Prefix            string        // prefix for perfdata labels and messages when checking multiple hosts
+	LaInterval        string        // loadavg interval which drives alarm level (1|5|15|all)
+	CPUCountSource    string        // loadavg core count source (hrprocessor|hrdevice|laTable|<number>). Empty means hrprocessor
+	Retries           int           // number of retries of failed snmp queries
+	RetryEmpty        int           // number of retries of empty snmp walk
+	MaxOids           int           // max number of oids in one snmp get request. 0 means no limit
+	RetryBackoff      time.Duration // delay before first retry, doubled on every next retry
+	WithState         bool          // annotate high cpu of components in transitional state
+	CPUProcess        bool          // annotate high cisco cpu with top cpu consuming process
+	WlcProcess        bool          // report top wireless control processes of high cisco 9800 wlc cpu
+	StackNames        bool          // name cisco catalyst stack member cpus by switch number
+	Normalize         bool          // add canonical 0-100 cpu utilization perfdata
+	AlarmOnNormalized bool          // alarm level is calculated from canonical cpu utilization only
+	StrictPerf        bool          // validate perfdata, drop dummy entries and quote labels consistently
+	Schema            bool          // append perfdata schema version of check type as schema_version perfdata
+	LabelStyle        string        // perfdata label style (legacy|snake). Empty means legacy
+	PrimaryOnly       bool          // alarm only jnx routing engines in master redundancy state
+	ReMode            string        // jnx routing engine alarming (any|master|worst|average). Empty means any
+	ReAggregate       string        // canonical jnx cpu utilization of routing engines (master|max). Empty disables
+	IncludeOffline    bool          // report jnx routing engines in unknown(1) and down(6) states
+	IncludeFabric     bool          // report jnx switch fabric board cpu as informational
+	Deadline          time.Duration // total snmp time budget. Components not collected in time are reported as unknown
+	SampleTwice       bool          // rcsw, moxasw and host checks take two samples
+	SampleInterval    time.Duration // delay between samples
+	SampleMode        string        // merge of samples (avg|max). Empty means avg
+	IntervalMode      string        // cisco and moxasw interval alarming (each|worst). Empty means each
+	RoundMode         string        // conversion of averaged and derived values to integers (round|ceil|floor)
+	Ha                bool          // report forti HA cluster members cpu
+	Unreachable       string        // level of failed reachable check (warning|critical)
+	ExpectComponents  int           // expected number of cpu components of jnx and cisco checks. 0 disables
+	ExpectLevel       string        // level of missing components (warning|critical)
+	ImbalancePct      int           // warn if spread of component cpu utilization exceeds this. 0 disables
+	Template          string        // text/template for check message. Replaces messages of check type
+	WithTemp          bool          // report jnx routing engine temperature
+	WithBuffer        bool          // report jnx routing engine buffer utilization
+	Spu               bool          // alarm busiest jnx SRX SPU. Routing engines are informational
+	CrossCheck        int           // tolerance of jnx cpu and 100 - ssCpuIdle in percentage points. 0 disables
+	WarnOnZero        bool          // warn if cpu utilization of host, sysstats, jnx or cisco check is exactly 0
+	StrictWalk        bool          // host check reports unknown if processor load walk looks incomplete
+	Aggregate         bool          // report min, max and average of components instead of component details
+	BaselineSigma     float64       // warn if cpu utilization exceeds rolling baseline mean by this many stddevs. 0 disables
+	Verify            bool          // check sysObjectID enterprise of device before check
+	CompThresholds    string        // comma separated <name or index>=<warn>/<crit> levels of jnx and cisco components
+	AlarmRegex        string        // only jnx and cisco components with matching name are alarmed. Empty means all
+	StateDir          string        // directory of state files. Empty means system temp dir
+	UnknownAs         string        // level of missing cpu data (unknown|warn|crit). Empty means unknown
+	Precision         int           // decimals of percentage perfdata calculated from averages and rates
+	SuppressAfterBoot int           // cpu alarms are capped to SuppressLevel if device booted less than this many seconds ago. 0 disables
+	SuppressLevel     string        // highest cpu alarm level after boot (warn|ok). Empty means warn
+	Deadband          int           // percentage points value must drop below level before alarm is lowered. 0 disables
+	Buffered          bool          // output is kept until Flush. Allows concurrent checks of hosts sharing check object
 	Debug             bool
+	norm              int64     // canonical cpu utilization
+	normSet           bool      // canonical cpu utilization is set
+	deadline          time.Time // end of snmp time budget
+	data              tmplData  // computed values available in message template
+	held              []output  // output held back while aggregating
+	buffer            []output  // output kept for Flush
+	metrics           []Metric  // valid performance data values
+	compLevels        levelMap  // warning and critical levels of components by name or index
+	warnList          []int     // per interval warning levels if set as comma separated list
+	critList          []int     // per interval critical levels if set as comma separated list
+	holding           bool      // output is held back
+	keeping           bool      // held output is check level output kept by aggregation
+	booting           bool      // device booted within SuppressAfterBoot
+	suppressed        bool      // cpu alarm was capped after boot
+	bandPrev          bandMap   // alarm levels of previous check used by Deadband
+	bandCur           bandMap   // alarm levels of this check saved for Deadband
+
+	alarmRe *regexp.Regexp // compiled AlarmRegex
+}
+
+// Computed values available in message template
+type tmplData struct {
+	Type       string           // check type
+	Load       int64            // canonical cpu utilization
+	CPUCount   int64            // number of cpu cores if known
+	Components map[string]int64 // cpu utilization of components by name
+	Messages   []string         // messages of check type
 }
 
+// .iso.org.dod.internet.mgmt.mib-2.system.sysDescr
+const sysDescr = ".1.3.6.1.2.1.1.1.0"
+
+// .iso.org.dod.internet.mgmt.mib-2.system.sysUpTime
+const sysUpTime = ".1.3.6.1.2.1.1.3.0"
+
 // .iso.org.dod.internet.mgmt.mib-2.system.sysObjectID
 const sysObjectID = ".1.3.6.1.2.1.1.2.0"
 
-// .iso.org.dod.internet.mgmt.mib-2.host.hrDevice.hrProcessorTable.hrProcessorEntry.hrProcessorLoad
-const hrProcessorLoad = ".1.3.6.1.2.1.25.3.3.1.2"
+// .iso.org.dod.internet.mgmt.mib-2.host.hrDevice.hrDeviceTable.hrDeviceEntry.hrDeviceType
+const hrDeviceType = ".1.3.6.1.2.1.25.3.2.1.2"
+
+// .iso.org.dod.internet.mgmt.mib-2.host.hrDevice.hrDeviceTypes.hrDeviceProcessor
+const hrDeviceProcessor = ".1.3.6.1.2.1.25.3.1.3"
+
+// .iso.org.dod.internet.mgmt.mib-2.host.hrDevice.hrProcessorTable.hrProcessorEntry.hrProcessorLoad
+const hrProcessorLoad = ".1.3.6.1.2.1.25.3.3.1.2"
+
+// .iso.org.dod.internet.private.enterprises.cisco.otherEnterprises.ciscosb.switch001.rndMng.rlCpuUtilDuringLastSecond
+const rlCpuUtilDuringLastSecond = ".1.3.6.1.4.1.9.6.1.101.1.7.0"
+
+// .iso.org.dod.internet.private.enterprises.cisco.otherEnterprises.ciscosb.switch001.rndMng.rlCpuUtilDuringLastMinute
+const rlCpuUtilDuringLastMinute = ".1.3.6.1.4.1.9.6.1.101.1.8.0"
+
+// .iso.org.dod.internet.private.enterprises.cisco.otherEnterprises.ciscosb.switch001.rndMng.rlCpuUtilDuringLast5Minutes
+const rlCpuUtilDuringLast5Minutes = ".1.3.6.1.4.1.9.6.1.101.1.9.0"
+
+// .iso.org.dod.internet.private.enterprises.bcsi.commDev.fibrechannel.fcSwitch.sw.swCpuOrMemoryUsage.swCpuUsage
+const swCpuUsage = ".1.3.6.1.4.1.1588.2.1.1.1.26.1.0"
+
+// .iso.org.dod.internet.private.enterprises.bcsi.commDev.fibrechannel.fcSwitch.sw.swCpuOrMemoryUsage.swCpuUsageLimit
+const swCpuUsageLimit = ".1.3.6.1.4.1.1588.2.1.1.1.26.3.0"
+
+// .iso.org.dod.internet.private.enterprises.bcsi.commDev.fibrechannel.fcSwitch.sw.swCpuOrMemoryUsage.swCpuPollingInterval
+const swCpuPollingInterval = ".1.3.6.1.4.1.1588.2.1.1.1.26.4.0"
+
+// .iso.org.dod.internet.private.enterprises.ucdavis.systemStats.ssCpuUser
+const ssCpuUser = ".1.3.6.1.4.1.2021.11.9.0"
+
+// .iso.org.dod.internet.private.enterprises.ucdavis.systemStats.ssCpuSystem
+const ssCpuSystem = ".1.3.6.1.4.1.2021.11.10.0"
+
+// .iso.org.dod.internet.private.enterprises.ucdavis.systemStats.ssCpuIdle
+const ssCpuRawIdle = ".1.3.6.1.4.1.2021.11.11.0"
+
+// .iso.org.dod.internet.private.enterprises.ucdavis.laTable.laEntry.laIndex
+const laIndex = ".1.3.6.1.4.1.2021.10.1.1"
+
+// .iso.org.dod.internet.private.enterprises.ucdavis.laTable.laEntry.laLoadInt
+const laLoadInt = ".1.3.6.1.4.1.2021.10.1.5"
+
+// .iso.org.dod.internet.private.enterprises.juniperMIB.jnxMibs.jnxBoxAnatomy.jnxOperatingTable.jnxOperatingEntry.jnxOperatingDescr
+const jnxOperatingDescr = ".1.3.6.1.4.1.2636.3.1.13.1.5"
+
+// .iso.org.dod.internet.private.enterprises.juniperMIB.jnxMibs.jnxBoxAnatomy.jnxOperatingTable.jnxOperatingEntry.jnxOperatingState
+const jnxOperatingState = ".1.3.6.1.4.1.2636.3.1.13.1.6"
+
+// .iso.org.dod.internet.private.enterprises.juniperMIB.jnxMibs.jnxBoxAnatomy.jnxOperatingTable.jnxOperatingEntry.jnxOperatingTemp
+const jnxOperatingTemp = ".1.3.6.1.4.1.2636.3.1.13.1.7"
+
+// .iso.org.dod.internet.private.enterprises.juniperMIB.jnxMibs.jnxBoxAnatomy.jnxOperatingTable.jnxOperatingEntry.jnxOperatingBuffer
+const jnxOperatingBuffer = ".1.3.6.1.4.1.2636.3.1.13.1.11"
+
+// .iso.org.dod.internet.private.enterprises.juniperMIB.jnxMibs.jnxBoxAnatomy.jnxOperatingTable.jnxOperatingEntry.jnxOperatingCPU
+const jnxOperatingCPU = ".1.3.6.1.4.1.2636.3.1.13.1.8"
+
+// .iso.org.dod.internet.private.enterprises.juniperMIB.jnxMibs.jnxJsMIB.jnxJsSPUMonitoringMIB.jnxJsSPUMonitoringObjects.jnxJsSPUMonitoringObjectsTable.jnxJsSPUMonitoringObjectsEntry.jnxJsSPUMonitoringCPUUsage
+const jnxJsSPUMonitoringCPUUsage = ".1.3.6.1.4.1.2636.3.39.1.12.1.1.1.4"
+
+// .iso.org.dod.internet.private.enterprises.juniperMIB.jnxMibs.jnxBoxAnatomy.jnxOperatingTable.jnxOperatingEntry.jnxOperating1MinLoadAvg
+const jnxOperating1MinLoadAvg = ".1.3.6.1.4.1.2636.3.1.13.1.20"
+
+// .iso.org.dod.internet.private.enterprises.juniperMIB.jnxMibs.jnxBoxAnatomy.jnxOperatingTable.jnxOperatingEntry.jnxOperating5MinLoadAvg
+const jnxOperating5MinLoadAvg = ".1.3.6.1.4.1.2636.3.1.13.1.21"
+
+// .iso.org.dod.internet.private.enterprises.juniperMIB.jnxMibs.jnxBoxAnatomy.jnxRedundancyTable.jnxRedundancyEntry.jnxRedundancyState
+const jnxRedundancyState = ".1.3.6.1.4.1.2636.3.1.14.1.7"
+
+// .iso.org.dod.internet.private.enterprises.cisco.ciscoMgmt.ciscoProcessMIB.ciscoProcessMIBObjects.cpmCPU.cpmCPUTotalTable.cpmCPUTotalEntry.cpmCPUTotalPhysicalIndex
+const cpmCPUTotalPhysicalIndex = ".1.3.6.1.4.1.9.9.109.1.1.1.1.2"
+
+// .iso.org.dod.internet.private.enterprises.cisco.ciscoMgmt.ciscoProcessMIB.ciscoProcessMIBObjects.cpmCPU.cpmCPUTotalTable.cpmCPUTotalEntry.cpmCPUTotal1minRev
+const cpmCPUTotal1minRev = ".1.3.6.1.4.1.9.9.109.1.1.1.1.7"
+
+// .iso.org.dod.internet.private.enterprises.cisco.ciscoMgmt.ciscoProcessMIB.ciscoProcessMIBObjects.cpmCPU.cpmCPUTotalTable.cpmCPUTotalEntry.cpmCPUTotal5minRev
+const cpmCPUTotal5minRev = ".1.3.6.1.4.1.9.9.109.1.1.1.1.8"
+
+// .iso.org.dod.internet.private.enterprises.cisco.ciscoMgmt.ciscoProcessMIB.ciscoProcessMIBObjects.cpmProcess.cpmProcessTable.cpmProcessEntry.cpmProcessName
+const cpmProcessName = ".1.3.6.1.4.1.9.9.109.1.2.1.1.2"
+
+// .iso.org.dod.internet.private.enterprises.cisco.ciscoMgmt.ciscoProcessMIB.ciscoProcessMIBObjects.cpmProcess.cpmProcessExtRevTable.cpmProcessExtRevEntry.cpmProcExtUtil1MinRev
+const cpmProcExtUtil1MinRev = ".1.3.6.1.4.1.9.9.109.1.2.3.1.6"
+
+// .iso.org.dod.internet.private.enterprises.cisco.ciscoMgmt.ciscoProcessMIB.ciscoProcessMIBObjects.cpmProcess.cpmProcessExtRevTable.cpmProcessExtRevEntry.cpmProcExtUtil5MinRev
+const cpmProcExtUtil5MinRev = ".1.3.6.1.4.1.9.9.109.1.2.3.1.7"
+
+// .iso.org.dod.internet.private.enterprises.cisco.ciscoMgmt.ciscoEntityFRUControlMIB.cefcMIBObjects.cefcModule.cefcModuleTable.cefcModuleEntry.cefcModuleOperStatus
+const cefcModuleOperStatus = ".1.3.6.1.4.1.9.9.117.1.2.1.1.2"
+
+// .iso.org.dod.internet.private.enterprises.timetra.timetraProducts.tmnxSRMIB.tmnxSRObjs.tmnxSysObjs.sysGenInfo.tmnxSysCpuMonTable.tmnxSysCpuMonEntry.tmnxSysCpuMonCpuIdle
+const tmnxSysCpuMonCpuIdle = ".1.3.6.1.4.1.6527.3.1.2.1.1.12.1.2"
+
+// .iso.org.dod.internet.mgmt.mib-2.entityMIB.entityMIBObjects.entityPhysical.entPhysicalTable.entPhysicalEntry.entPhysicalName
+const entPhysicalName = ".1.3.6.1.2.1.47.1.1.1.1.7"
+
+// .iso.org.dod.internet.mgmt.mib-2.entityMIB.entityMIBObjects.entityPhysical.entPhysicalTable.entPhysicalEntry.entPhysicalContainedIn
+const entPhysicalContainedIn = ".1.3.6.1.2.1.47.1.1.1.1.4"
+
+// .iso.org.dod.internet.mgmt.mib-2.entityMIB.entityMIBObjects.entityPhysical.entPhysicalTable.entPhysicalEntry.entPhysicalClass
+const entPhysicalClass = ".1.3.6.1.2.1.47.1.1.1.1.5"
+
+// .iso.org.dod.internet.mgmt.mib-2.entityMIB.entityMIBObjects.entityPhysical.entPhysicalTable.entPhysicalEntry.entPhysicalParentRelPos
+const entPhysicalParentRelPos = ".1.3.6.1.2.1.47.1.1.1.1.6"
+
+// entPhysicalClass value of stack entity
+const entClassStack = 11
+
+// .iso.org.dod.internet.private.enterprises.ruggedcom.ruggedcomMgmt.rcSysInfo.rcDeviceStatus.rcDeviceStsCpuUsagePercent
+const rcDeviceStsCpuUsagePercent = ".1.3.6.1.4.1.15004.4.2.2.6.0"
+
+// .iso.org.dod.internet.private.enterprises.fortinet.fnFortiGateMib.fgSystem.fgSystemInfo.fgSysCpuUsage
+const fgSysCpuUsage = ".1.3.6.1.4.1.12356.101.4.1.3.0"
+
+// .iso.org.dod.internet.private.enterprises.fortinet.fnFortiGateMib.fgHighAvailability.fgHaInfo.fgHaSystemMode
+const fgHaSystemMode = ".1.3.6.1.4.1.12356.101.13.1.1.0"
+
+// .iso.org.dod.internet.private.enterprises.fortinet.fnFortiGateMib.fgHighAvailability.fgHaTables.fgHaStatsTable.fgHaStatsEntry.fgHaStatsSerial
+const fgHaStatsSerial = ".1.3.6.1.4.1.12356.101.13.2.1.1.2"
+
+// .iso.org.dod.internet.private.enterprises.fortinet.fnFortiGateMib.fgHighAvailability.fgHaTables.fgHaStatsTable.fgHaStatsEntry.fgHaStatsCpuUsage
+const fgHaStatsCpuUsage = ".1.3.6.1.4.1.12356.101.13.2.1.1.3"
+
+// .iso.org.dod.internet.private.enterprises.tplink.tplinkMgmt.tplinkSysMonitorMIB.tplinkSysMonitorMIBObjects.tpSysMonitorCpu.tpSysMonitorCpuTable.tpSysMonitorCpuEntry.tpSysMonitorCpu1Minute
+const tpSysMonitorCpu1Minute = ".1.3.6.1.4.1.11863.6.4.1.1.1.1.3"
+
+// .iso.org.dod.internet.private.enterprises.arubanetworks.arubaEnterpriseMibModules.arubaInstant.aiMIB.aiObjects.aiAccessPointTable.aiAccessPointEntry.aiAPName
+const aiAPName = ".1.3.6.1.4.1.14823.2.3.3.1.2.1.1.2"
+
+// .iso.org.dod.internet.private.enterprises.arubanetworks.arubaEnterpriseMibModules.arubaInstant.aiMIB.aiObjects.aiAccessPointTable.aiAccessPointEntry.aiAPCPUUtilization
+const aiAPCPUUtilization = ".1.3.6.1.4.1.14823.2.3.3.1.2.1.1.7"
+
+// .iso.org.dod.internet.private.enterprises.infoblox.ibProduct.ibOne.ibPlatformOne.ibPlatformOneMIBObjects.ibSystemMonitor.ibSystemMonitorCpu.ibSystemMonitorCpuUsage
+const ibSystemMonitorCpuUsage = ".1.3.6.1.4.1.7779.3.1.1.2.1.8.1.1.0"
+
+// .iso.org.dod.internet.private.enterprises.netgear.ng7000managedswitch.fastPathSwitching.agentInfoGroup.agentSwitchCpuProcessGroup.agentSwitchCpuProcessTotalUtilization
+const agentSwitchCpuProcessTotalUtilization = ".1.3.6.1.4.1.4526.10.1.1.4.9"
+
+// .iso.org.dod.internet.private.enterprises.netgear.ngfastpath.fastPathSwitching.agentInfoGroup.agentSwitchCpuProcessGroup.agentSwitchCpuProcessTotalUtilization
+const fpSwitchCpuProcessTotalUtilization = ".1.3.6.1.4.1.4526.11.1.1.4.9"
+
+// .iso.org.dod.internet.private.enterprises.viptela.viptelaOperSystem.systemStatus.systemStatusCpuUser
+const systemStatusCpuUser = ".1.3.6.1.4.1.41916.11.1.16.0"
+
+// .iso.org.dod.internet.private.enterprises.viptela.viptelaOperSystem.systemStatus.systemStatusCpuSystem
+const systemStatusCpuSystem = ".1.3.6.1.4.1.41916.11.1.17.0"
+
+// .iso.org.dod.internet.private.enterprises.viptela.viptelaOperSystem.systemStatus.systemStatusCpuIdle
+const systemStatusCpuIdle = ".1.3.6.1.4.1.41916.11.1.18.0"
+
+// .iso.org.dod.internet.private.enterprises.fokus.begemot.begemotPf.begemotPfObjects.pfStateTable.pfStateTableCount
+const pfStateTableCount = ".1.3.6.1.4.1.12325.1.200.1.3.1.0"
+
+// .iso.org.dod.internet.private.enterprises.fokus.begemot.begemotPf.begemotPfObjects.pfLimits.pfLimitsStates
+const pfLimitsStates = ".1.3.6.1.4.1.12325.1.200.1.5.1.0"
+
+// Default moxasw cpuLoading5s, cpuLoading30s, cpuLoading300s oid suffixes relative to sysObjectID
+var moxaSuffixes = []string{".1.53.0", ".1.54.0", ".1.55.0"}
+
+// Alarm level decrements of loadavg 1, 5 and 15 min values
+var loadavgDecs = []int{0, 5, 10}
+
+// Alarm level decrements of cisco 1 and 5 min values
+var ciscoDecs = []int{0, 5}
+
+// Alarm level decrements of timetra 1, 60 and 300 sec values
+var timetraDecs = []int{0, 5, 10}
+
+// Alarm level decrements of moxasw 5, 30 and 300 sec values
+var moxaDecs = []int{0, 5, 10}
+
+// Check levels of missing cpu data by Load.UnknownAs
+var absentLevels = map[string]int{"": 3, "unknown": 3, "warn": 1, "crit": 2}
+
+// Highest cpu alarm levels after boot by Load.SuppressLevel
+var suppressLevels = map[string]int{"": 1, "warn": 1, "ok": 0}
+
+// Transitional jnxOperatingState values where cpu spike is expected
+var jnxTransStates = map[int64]string{3: "ready", 4: "reset"}
+
+// jnxOperatingState values of absent or offline components. Running(2),
+// ready(3), reset(4), runningAtFullSpeed(5) and standby(7) are online.
+var jnxOfflineStates = map[int64]bool{1: true, 6: true}
+
+// Transitional cefcModuleOperStatus values where cpu spike is expected
+var cefcTransStates = map[int64]string{5: "boot", 6: "selfTest", 16: "poweredUp", 21: "syncInProgress"}
+
+// Matches routing engine descriptors on Junos Evolved (fe. "Routing Engine 0", "RE0", "re1")
+var jnxEvoRe = regexp.MustCompile(`(?i)(routing engine|^re ?[0-9]+\b)`)
+
+// Matches IOS-XR route processor node locations (fe. "0/RP0/CPU0", "0/RSP1/CPU0")
+var xrRp = regexp.MustCompile(`(?i)/(rp|rsp)[0-9]+/`)
+
+// Matches characters replaced by underscore in snake case perfdata labels
+var snakeSep = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Matches Catalyst 9800 wlc wireless control process names (fe. "wncd_0", "wncmgrd")
+var wlcProc = regexp.MustCompile(`(?i)^(wncd|wncmgrd|mobilityd|nmspd|rrm)`)
+
+// Matches intervals of Netgear agentSwitchCpuProcessTotalUtilization (fe. "5 Secs ( 12.3456%)   60 Secs ( 10.2311%)")
+var netgearUtil = regexp.MustCompile(`(\d+)\s*Secs\s*\(\s*([0-9.]+)%\s*\)`)
+
+// Matches numeric oid fragment (fe. "1.53.0" or ".1.53.0")
+var oidFragment = regexp.MustCompile(`^\.?[0-9]+(\.[0-9]+)*$`)
+
+// Matches cBR-8 supervisor cpu names (fe. "cpu R0/0", "Supervisor 4")
+var cmtsSup = regexp.MustCompile(`(?i)(\bsup|supervisor|\br[0-9]+\b)`)
+
+// Route processor and supervisor name patterns of check types alarming only
+// control plane cpus
+var controlCPU = map[string]*regexp.Regexp{
+	"iosxr": xrRp,
+	"cmts":  cmtsSup,
+}
+
+// Check types where exactly 0% cpu utilization means stuck agent
+var zeroTypes = map[string]bool{
+	"host":     true,
+	"sysstats": true,
+	"jnx":      true,
+	"cisco":    true,
+}
+
+// Matches Juniper switch fabric board descriptors
+var jnxFabric = regexp.MustCompile(`(?i)(fabric|\bsib\b|\bsfb\b)`)
+
+// Warning and critical levels by component name or index
+type levelMap map[string][2]string
+
+// Alarm levels by component name or perfdata label of calculated levels
+type bandMap map[string]int
+
+// Performance data value of check
+type Metric struct {
+	Label string // label without Load.Prefix and quotes
+	Value string
+	Unit  string
+}
+
+// Check message or performance data entry held back for aggregation
+type output struct {
+	perf                                     bool // performance data entry
+	absent                                   bool // message of missing cpu data
+	keep                                     bool // check level output kept by aggregation
+	level                                    int
+	short, long                              string
+	label, value, unit, warn, crit, min, max string
+}
+
+// SNMP query planned by check type. Index placeholders are in angle brackets.
+type query struct {
+	oid  string
+	walk bool
+}
+
+// Check type definition
+type checkType struct {
+	run     func(l *Load) error            // does the check
+	queries func(l *Load) ([]query, error) // snmp queries done by check
+	decs    []int                          // alarm level decrements of derived intervals
+	names   []string                       // names of derived intervals
+	warn    string                         // default warning level of check type
+	crit    string                         // default critical level of check type
+	vendors []string                       // private enterprise numbers of sysObjectID of supported devices
+	schema  int                            // perfdata schema version of check type. 0 means schemaVersion
+}
+
+// Perfdata schema version of check types. Raise it (or schema of a check type)
+// when perfdata labels or units reported to Load.Metrics change.
+const schemaVersion = 1
+
+// Offset added to schema version when perfdata labels are renamed by snake label style
+const snakeSchema = 1000
+
+// Registry of supported check types
+var checkTypes = map[string]checkType{
+	"host": {
+		run: (*Load).hostLoad,
+		queries: func(l *Load) ([]query, error) {
+			q := []query{{hrProcessorLoad, true}}
+			if l.StrictWalk {
+				q = append(q, query{hrDeviceType, true})
+			}
+
+			return q, nil
+		},
+	},
+	"sysstats": {
+		run: (*Load).cpuLoad,
+		queries: func(l *Load) ([]query, error) {
+			return []query{{ssCpuUser, false}, {ssCpuSystem, false}, {ssCpuRawIdle, false}}, nil
+		},
+	},
+	"loadavg": {
+		run: (*Load).sysLoad,
+		queries: func(l *Load) ([]query, error) {
+			var q []query
+			switch l.CPUCountSource {
+			case "", "hrprocessor":
+				q = append(q, query{hrProcessorLoad, true})
+			case "hrdevice":
+				q = append(q, query{hrDeviceType, true})
+			case "laTable":
+				q = append(q, query{laIndex, true})
+			}
+			return append(q,
+				query{laLoadInt + ".1", false}, query{laLoadInt + ".2", false}, query{laLoadInt + ".3", false},
+			), nil
+		},
+		decs:  loadavgDecs,
+		names: []string{"l1", "l5", "l15"},
+	},
+	"jnx": {
+		run:     (*Load).jnxLoad,
+		vendors: []string{"2636"},
+		queries: func(l *Load) ([]query, error) {
+			q := []query{{jnxOperatingDescr, true}, {sysDescr, false}}
+			if l.WithState || !l.IncludeOffline {
+				q = append(q, query{jnxOperatingState, true})
+			}
+			q = append(q,
+				query{jnxOperatingCPU + ".<index>", false},
+				query{jnxOperating1MinLoadAvg + ".<index>", false},
+				query{jnxOperating5MinLoadAvg + ".<index>", false},
+			)
+			if l.WithTemp {
+				q = append(q, query{jnxOperatingTemp + ".<index>", false})
+			}
+			if l.WithBuffer {
+				q = append(q, query{jnxOperatingBuffer + ".<index>", false})
+			}
+			if l.PrimaryOnly || l.ReMode == "master" || l.ReAggregate == "master" {
+				q = append(q, query{jnxRedundancyState, true})
+			}
+			if l.IncludeFabric {
+				q = append(q, query{jnxOperatingCPU + ".<fabric index>", false})
+			}
+			if l.Spu {
+				q = append(q, query{jnxJsSPUMonitoringCPUUsage, true})
+			}
+			if l.CrossCheck > 0 {
+				q = append(q, query{ssCpuRawIdle, false}, query{jnxRedundancyState, true})
+			}
+
+			return q, nil
+		},
+	},
+	"cisco": {
+		run:     (*Load).ciscoLoad,
+		vendors: []string{"9"},
+		queries: ciscoQueries,
+		decs:    ciscoDecs,
+		names:   []string{"1m", "5m"},
+	},
+	"iosxr": {
+		run:     (*Load).ciscoLoad,
+		vendors: []string{"9"},
+		queries: ciscoQueries,
+		decs:    ciscoDecs,
+		names:   []string{"1m", "5m"},
+	},
+	"cmts": {
+		run:     (*Load).ciscoLoad,
+		vendors: []string{"9"},
+		queries: ciscoQueries,
+		decs:    ciscoDecs,
+		names:   []string{"1m", "5m"},
+	},
+	"ciscosb": {
+		run:     (*Load).ciscoSbLoad,
+		vendors: []string{"9"},
+		queries: func(l *Load) ([]query, error) {
+			return []query{
+				{rlCpuUtilDuringLastSecond, false},
+				{rlCpuUtilDuringLastMinute, false},
+				{rlCpuUtilDuringLast5Minutes, false},
+			}, nil
+		},
+		decs:  ciscoDecs,
+		names: []string{"1m", "5m"},
+	},
+	"timetra": {
+		run:     (*Load).timetraLoad,
+		vendors: []string{"6527"},
+		queries: func(l *Load) ([]query, error) {
+			return []query{
+				{tmnxSysCpuMonCpuIdle + ".1", false},
+				{tmnxSysCpuMonCpuIdle + ".60", false},
+				{tmnxSysCpuMonCpuIdle + ".300", false},
+			}, nil
+		},
+		decs:  timetraDecs,
+		names: []string{"u1", "u60", "u300"},
+	},
+	"rcsw": {
+		run:     (*Load).ruggedSwLoad,
+		vendors: []string{"15004"},
+		queries: func(l *Load) ([]query, error) {
+			return []query{{rcDeviceStsCpuUsagePercent, false}}, nil
+		},
+	},
+	"moxasw": {
+		run:     (*Load).moxaSwLoad,
+		vendors: []string{"8691"},
+		queries: func(l *Load) ([]query, error) {
+			sfx, err := parseOidSuffixes(l.MoxaSuffix, moxaSuffixes)
+			if err != nil {
+				return nil, fmt.Errorf("moxa suffix error: %v", err)
+			}
+
+			q := []query{{sysObjectID, false}}
+			for _, s := range sfx {
+				q = append(q, query{"<sysObjectID>" + s, false})
+			}
+
+			return q, nil
+		},
+		decs:  moxaDecs,
+		names: []string{"5s", "30s", "300s"},
+	},
+	"meraki": {
+		run:     (*Load).merakiLoad,
+		vendors: []string{"29671"},
+		queries: func(l *Load) ([]query, error) {
+			return []query{
+				{hrProcessorLoad, true},
+				{ssCpuUser, false}, {ssCpuSystem, false}, {ssCpuRawIdle, false},
+				{sysObjectID, false},
+			}, nil
+		},
+	},
+	"forti": {
+		run:     (*Load).fortiLoad,
+		vendors: []string{"12356"},
+		queries: func(l *Load) ([]query, error) {
+			q := []query{{fgSysCpuUsage, false}}
+			if l.Ha {
+				q = append(q, query{fgHaSystemMode, false}, query{fgHaStatsCpuUsage, true}, query{fgHaStatsSerial, true})
+			}
+
+			return q, nil
+		},
+	},
+	"supermicro": {
+		run: (*Load).supermicroLoad,
+		queries: func(l *Load) ([]query, error) {
+			return []query{
+				{hrProcessorLoad, true},
+				{laLoadInt + ".1", false}, {laLoadInt + ".2", false}, {laLoadInt + ".3", false},
+			}, nil
+		},
+	},
+	"sdwan": {
+		run:     (*Load).sdwanLoad,
+		vendors: []string{"9", "41916"},
+		queries: func(l *Load) ([]query, error) {
+			q := []query{{sysObjectID, false}}
+			cq, _ := ciscoQueries(l)
+			q = append(q, cq...)
+
+			return append(q,
+				query{systemStatusCpuUser, false}, query{systemStatusCpuSystem, false}, query{systemStatusCpuIdle, false},
+				query{hrProcessorLoad, true},
+			), nil
+		},
+	},
+	"router-linux": {
+		run: (*Load).routerLinuxLoad,
+		queries: func(l *Load) ([]query, error) {
+			return []query{
+				{hrProcessorLoad, true}, {ssCpuRawIdle, false},
+				{ssCpuUser, false}, {ssCpuSystem, false},
+				{laLoadInt + ".<1,2,3>", false},
+			}, nil
+		},
+	},
+	"brocadefc": {
+		run:     (*Load).brocadeFcLoad,
+		vendors: []string{"1588"},
+		queries: func(l *Load) ([]query, error) {
+			return []query{{swCpuUsage, false}, {swCpuUsageLimit, false}, {swCpuPollingInterval, false}}, nil
+		},
+	},
+	"mikrotik": {
+		run:     (*Load).mikrotikLoad,
+		vendors: []string{"14988"},
+		queries: func(l *Load) ([]query, error) {
+			return []query{{hrProcessorLoad, true}, {laLoadInt + ".<1,2,3>", false}}, nil
+		},
+	},
+	"appliance": {
+		run: (*Load).applianceLoad,
+		queries: func(l *Load) ([]query, error) {
+			if l.ApplianceOid != "" {
+				return []query{{l.ApplianceOid, false}}, nil
+			}
+			return []query{{hrProcessorLoad, true}}, nil
+		},
+		warn: "90",
+		crit: "98",
+	},
+	"firstof": {
+		run: (*Load).firstofLoad,
+		queries: func(l *Load) ([]query, error) {
+			oids, err := l.probeOids()
+			if err != nil {
+				return nil, err
+			}
+
+			q := make([]query, len(oids))
+			for i, o := range oids {
+				q[i] = query{o, o == hrProcessorLoad}
+			}
+			return q, nil
+		},
+	},
+	"ilom": {
+		run: (*Load).ilomLoad,
+		queries: func(l *Load) ([]query, error) {
+			return []query{
+				{sysObjectID, false}, {hrProcessorLoad, true},
+				{ssCpuUser, false}, {ssCpuSystem, false}, {ssCpuRawIdle, false},
+			}, nil
+		},
+	},
+	"sdwan-versa": {
+		run: (*Load).sdwanEdgeLoad,
+		queries: func(l *Load) ([]query, error) {
+			return []query{{sysObjectID, false}, {hrProcessorLoad, true}}, nil
+		},
+	},
+	"velocloud": {
+		run: (*Load).sdwanEdgeLoad,
+		queries: func(l *Load) ([]query, error) {
+			return []query{{sysObjectID, false}, {hrProcessorLoad, true}}, nil
+		},
+	},
+	"endpoint": {
+		run: (*Load).endpointLoad,
+		queries: func(l *Load) ([]query, error) {
+			return []query{{hrProcessorLoad, true}}, nil
+		},
+		warn: "70",
+		crit: "85",
+	},
+	"tplink": {
+		run:     (*Load).tplinkLoad,
+		vendors: []string{"11863"},
+		queries: func(l *Load) ([]query, error) {
+			return []query{{tpSysMonitorCpu1Minute, true}, {hrProcessorLoad, true}, {sysObjectID, false}}, nil
+		},
+	},
+	"arubainstant": {
+		run:     (*Load).arubaInstantLoad,
+		vendors: []string{"14823"},
+		queries: func(l *Load) ([]query, error) {
+			return []query{{aiAPCPUUtilization, true}, {aiAPName, true}}, nil
+		},
+	},
+	"opnsense": {
+		run: (*Load).opnsenseLoad,
+		queries: func(l *Load) ([]query, error) {
+			return []query{{hrProcessorLoad, true}, {pfStateTableCount, false}, {pfLimitsStates, false}}, nil
+		},
+		warn: "80",
+		crit: "90",
+	},
+	"infoblox": {
+		run:     (*Load).infobloxLoad,
+		vendors: []string{"7779"},
+		queries: func(l *Load) ([]query, error) {
+			return []query{{ibSystemMonitorCpuUsage, false}, {hrProcessorLoad, true}}, nil
+		},
+	},
+	"netgear": {
+		run:     (*Load).netgearLoad,
+		vendors: []string{"4526"},
+		queries: func(l *Load) ([]query, error) {
+			return []query{
+				{agentSwitchCpuProcessTotalUtilization, true}, {fpSwitchCpuProcessTotalUtilization, true},
+				{hrProcessorLoad, true}, {sysObjectID, false},
+			}, nil
+		},
+	},
+	"reachable": {
+		run: (*Load).reachable,
+		queries: func(l *Load) ([]query, error) {
+			return []query{{sysUpTime, false}}, nil
+		},
+	},
+	"rate": {
+		run: (*Load).rateLoad,
+		queries: func(l *Load) ([]query, error) {
+			oids, err := l.rateOids()
+			if err != nil {
+				return nil, err
+			}
+
+			return []query{{oids[0], false}, {oids[1], false}, {sysUpTime, false}}, nil
+		},
+	},
+}
+
+// Returns snmp queries of cisco check
+func ciscoQueries(l *Load) ([]query, error) {
+	q := []query{
+		{cpmCPUTotalPhysicalIndex, true},
+		{entPhysicalName + ".<entity index>", false},
+	}
+	if l.StackNames && l.Ctype == "cisco" {
+		q = append(q, query{entPhysicalContainedIn, true}, query{entPhysicalClass, true}, query{entPhysicalParentRelPos, true})
+	}
+	if l.WithState {
+		q = append(q, query{cefcModuleOperStatus, true})
+	}
+	q = append(q,
+		query{cpmCPUTotal1minRev + ".<index>", false},
+		query{cpmCPUTotal5minRev + ".<index>", false},
+	)
+	if l.CPUProcess {
+		q = append(q, query{cpmProcExtUtil5MinRev, true}, query{cpmProcessName, true})
+	}
+	if l.WlcProcess {
+		q = append(q, query{cpmProcExtUtil1MinRev, true}, query{cpmProcessName, true})
+	}
+
+	return q, nil
+}
+
+// Returns default warning and critical levels of check type. Returns false if
+// check type uses global defaults.
+func DefaultLevels(ctype string) (string, string, bool) {
+	t, ok := checkTypes[ctype]
+	if !ok || t.warn == "" {
+		return "", "", false
+	}
+
+	return t.warn, t.crit, true
+}
+
+// Returns perfdata schema version of check type and label style
+func SchemaVersion(ctype, labelStyle string) int {
+	v := schemaVersion
+	if t, ok := checkTypes[ctype]; ok && t.schema > 0 {
+		v = t.schema
+	}
+	if labelStyle == "snake" {
+		v += snakeSchema
+	}
+
+	return v
+}
+
+// Returns names of check types in alphabetical order
+func Types() []string {
+	out := make([]string, 0, len(checkTypes))
+	for k := range checkTypes {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+
+	return out
+}
+
+// Do the work
+func (l *Load) Get() error {
+	t, ok := checkTypes[l.Ctype]
+	if !ok {
+		return fmt.Errorf("no such check type")
+	}
+
+	switch l.RoundMode {
+	case "", "round", "ceil", "floor":
+	default:
+		return fmt.Errorf("not valid round mode - %s", l.RoundMode)
+	}
+
+	switch l.SampleMode {
+	case "", "avg", "max":
+	default:
+		return fmt.Errorf("not valid sample mode - %s", l.SampleMode)
+	}
+
+	switch l.IntervalMode {
+	case "", "each", "worst":
+	default:
+		return fmt.Errorf("not valid interval mode - %s", l.IntervalMode)
+	}
+
+	switch l.ReMode {
+	case "", "any", "master", "worst", "average":
+	default:
+		return fmt.Errorf("not valid routing engine mode - %s", l.ReMode)
+	}
+
+	err := l.parseCompThresholds()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := absentLevels[l.UnknownAs]; !ok {
+		return fmt.Errorf("not valid unknown-as level - %s", l.UnknownAs)
+	}
+
+	switch l.LabelStyle {
+	case "", "legacy", "snake":
+	default:
+		return fmt.Errorf("not valid label style - %s", l.LabelStyle)
+	}
+
+	switch l.ReAggregate {
+	case "", "master", "max":
+	default:
+		return fmt.Errorf("not valid routing engine aggregate - %s", l.ReAggregate)
+	}
+
+	if _, ok := suppressLevels[l.SuppressLevel]; !ok {
+		return fmt.Errorf("not valid suppress level - %s", l.SuppressLevel)
+	}
+
+	err = l.parseIntervalLevels(t.decs)
+	if err != nil {
+		return err
+	}
+
+	l.alarmRe, err = regexp.Compile(l.AlarmRegex)
+	if err != nil {
+		return fmt.Errorf("not valid alarm regex: %v", err)
+	}
+
+	// Queries in progress are cancelled when deadline is reached
+	if l.Deadline > 0 {
+		l.deadline = time.Now().Add(l.Deadline)
+		if l.Sess != nil && l.Sess.Snmp != nil {
+			ctx, cancel := context.WithDeadline(context.Background(), l.deadline)
+			defer cancel()
+			l.Sess.Snmp.Context = ctx
+		}
+	}
+
+	if l.Verify && len(t.vendors) > 0 {
+		ok, err := l.verify(t.vendors)
+		if err != nil || !ok {
+			return err
+		}
+	}
+
+	if l.SuppressAfterBoot > 0 {
+		l.bootWindow()
+	}
+
+	if l.Deadband > 0 {
+		l.bandPrev, l.bandCur = make(bandMap), make(bandMap)
+		state.Load(l.StateDir, l.Sess.Host, l.Ctype, "deadband", &l.bandPrev)
+	}
+
+	l.holding = l.Aggregate
+	err = t.run(l)
+	l.holding = false
+	if err != nil {
+		return err
+	}
+
+	if l.Aggregate {
+		err := l.aggregate()
+		if err != nil {
+			return err
+		}
+	}
+
+	if l.suppressed {
+		l.addMsg(0, fmt.Sprintf("cpu alarm suppressed, device booted less than %ds ago", l.SuppressAfterBoot), "")
+	}
+
+	if l.WarnOnZero && zeroTypes[l.Ctype] && l.normSet && l.norm == 0 {
+		l.setLevel(1)
+		l.addMsg(1, "cpu utilization is exactly 0%, snmp agent may be stuck and need restart", "")
+	}
+
+	if l.BaselineSigma > 0 && l.normSet {
+		err := l.baseline()
+		if err != nil {
+			return err
+		}
+	}
+
+	if (l.Normalize || l.AlarmOnNormalized || (l.ReAggregate != "" && l.Ctype == "jnx")) && l.normSet {
+		w, c := "", ""
+		if l.AlarmOnNormalized {
+			level, err := l.checkLevel("cpu utilization", l.norm, l.Warn, l.Crit)
+			if err != nil {
+				return fmt.Errorf("alarm level error: %v", err)
+			}
+			w, c = l.Warn, l.Crit
+			l.addMsg(level, fmt.Sprintf("utilization %d%%", l.norm), "")
+		}
+		l.addPerf("'cpu utilization'", fmt.Sprintf("%d", l.norm), "%", w, c, "0", "100")
+	}
+
+	if l.Schema {
+		l.addPerf("schema_version", strconv.Itoa(SchemaVersion(l.Ctype, l.LabelStyle)), "", "", "", "", "")
+	}
+
+	if l.Deadband > 0 {
+		if err := state.Save(l.StateDir, l.Sess.Host, l.Ctype, "deadband", l.bandCur); err != nil {
+			return err
+		}
+	}
+
+	// Template message is rendered last to get final check level and all messages
+	if l.Template != "" {
+		err := l.templateMsg()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns snmp queries of check in order of execution. Queries of -verify and
+// -suppress-after-boot are done before queries of check type.
+func (l *Load) plan(t checkType) ([]query, error) {
+	var q []query
+	if l.Verify && len(t.vendors) > 0 {
+		q = append(q, query{sysObjectID, false})
+	}
+	if l.SuppressAfterBoot > 0 {
+		q = append(q, query{sysUpTime, false})
+	}
+
+	tq, err := t.queries(l)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(q, tq...), nil
+}
+
+// Print snmp queries and alarm levels of check type without doing any snmp traffic
+func (l *Load) DryRun() error {
+	t, ok := checkTypes[l.Ctype]
+	if !ok {
+		return fmt.Errorf("no such check type")
+	}
+
+	err := l.parseIntervalLevels(t.decs)
+	if err != nil {
+		return err
+	}
+
+	q, err := l.plan(t)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("check type: %s\n", l.Ctype)
+	for _, v := range q {
+		m := "get"
+		if v.walk {
+			m = "walk"
+		}
+		fmt.Printf("%s %s\n", m, v.oid)
+	}
+
+	if t.decs == nil {
+		fmt.Printf("levels: warning %s, critical %s\n", l.Warn, l.Crit)
+		return nil
+	}
+
+	wl, cl, err := l.derivedLevels(t.decs)
+	if err != nil {
+		return err
+	}
+	for i := range t.decs {
+		fmt.Printf("levels %s: warning %d, critical %d\n", t.names[i], wl[i], cl[i])
+	}
+	if l.Ctype == "loadavg" {
+		fmt.Println("loadavg levels are multiplied by cpu count")
+	}
+
+	return nil
+}
+
+// Print unique base oids queried by check type without doing any snmp traffic.
+// Index placeholders of dynamically built oids are left out. Oids relative to
+// sysObjectID of device are listed only in DryRun plan.
+func (l *Load) Oids() error {
+	t, ok := checkTypes[l.Ctype]
+	if !ok {
+		return fmt.Errorf("no such check type")
+	}
+
+	q, err := l.plan(t)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	for _, v := range q {
+		o := v.oid
+		if strings.HasPrefix(o, "<") {
+			continue
+		}
+		if i := strings.Index(o, ".<"); i >= 0 {
+			o = o[:i]
+		}
+		if seen[o] {
+			continue
+		}
+		seen[o] = true
+		fmt.Println(o)
+	}
+
+	return nil
+}
+
+// Returns integer warning and critical levels decreased by decs.
+// Per interval levels are returned instead if set.
+func (l *Load) derivedLevels(decs []int) ([]int, []int, error) {
+	if l.warnList != nil && len(l.warnList) == len(decs) {
+		return l.warnList, l.critList, nil
+	}
+
+	return decreasedLevels(l.Warn, l.Crit, decs)
+}
+
+// Parse comma separated per interval levels (fe. "85,80,75") of check types
+// with derived interval levels. Warn and Crit are set to levels of first
+// interval. Single levels are left for derivation.
+func (l *Load) parseIntervalLevels(decs []int) error {
+	l.warnList, l.critList = nil, nil
+	if !strings.Contains(l.Warn, ",") && !strings.Contains(l.Crit, ",") {
+		return nil
+	}
+
+	if len(decs) < 2 {
+		return fmt.Errorf("per interval levels are not supported by check type %s", l.Ctype)
+	}
+
+	parse := func(s, name string) ([]int, error) {
+		// Single level of other threshold is derived as usual
+		if !strings.Contains(s, ",") {
+			v, err := strconv.Atoi(s)
+			if err != nil {
+				return nil, fmt.Errorf("%s level must be integer: %v", name, err)
+			}
+			out := make([]int, len(decs))
+			for i, d := range decs {
+				out[i] = v - d
+			}
+			return out, nil
+		}
+
+		parts := strings.Split(s, ",")
+		if len(parts) != len(decs) {
+			return nil, fmt.Errorf("%s levels count must be %d - %s", name, len(decs), s)
+		}
+		out := make([]int, len(parts))
+		for i, p := range parts {
+			v, err := strconv.Atoi(strings.TrimSpace(p))
+			if err != nil {
+				return nil, fmt.Errorf("%s level must be integer: %v", name, err)
+			}
+			out[i] = v
+		}
+
+		return out, nil
+	}
+
+	wl, err := parse(l.Warn, "warning")
+	if err != nil {
+		return err
+	}
+	cl, err := parse(l.Crit, "critical")
+	if err != nil {
+		return err
+	}
+
+	l.warnList, l.critList = wl, cl
+	l.Warn, l.Crit = strconv.Itoa(wl[0]), strconv.Itoa(cl[0])
+
+	return nil
+}
+
+// Returns integer levels w and c decreased by decs
+func decreasedLevels(w, c string, decs []int) ([]int, []int, error) {
+	wInt, err := strconv.Atoi(w)
+	if err != nil {
+		return nil, nil, fmt.Errorf("warning level must be integer: %v", err)
+	}
+
+	cInt, err := strconv.Atoi(c)
+	if err != nil {
+		return nil, nil, fmt.Errorf("critical level must be integer: %v", err)
+	}
+
+	wl := make([]int, len(decs))
+	cl := make([]int, len(decs))
+	for i, d := range decs {
+		wl[i] = wInt - d
+		cl[i] = cInt - d
+	}
+
+	return wl, cl, nil
+}
+
+// Get load data using hrProcessorLoad oid
+func (l *Load) hostLoad() error {
+	// Do SNMP query
+	res, err := l.walk(hrProcessorLoad, true, true)
+	if err != nil {
+		return fmt.Errorf("snmp error: %v", err)
+	}
+	if l.SampleTwice {
+		res, err = l.secondSample(res, func() (snmphelper.SnmpOut, error) {
+			return l.walk(hrProcessorLoad, true, true)
+		})
+		if err != nil {
+			return fmt.Errorf("snmp error: %v", err)
+		}
+	}
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(res))
+	}
+
+	// Failed walk never returns partial data. Compare processor count of hrDeviceTable
+	// to catch walks truncated by agent without error.
+	if l.StrictWalk {
+		dev, err := l.walk(hrDeviceType, true, true)
+		if err != nil {
+			return fmt.Errorf("snmp error: %v", err)
+		}
+
+		cnt := 0
+		for _, d := range dev {
+			if d.ObjectIdentifier == hrDeviceProcessor {
+				cnt++
+			}
+		}
+		if cnt != len(res) {
+			l.addMsg(3, fmt.Sprintf("incomplete walk, %d of %d processors returned load", len(res), cnt), "")
+			return nil
+		}
+	}
+
+	return l.hostLoadData(res)
+}
+
+// Report average load of hrProcessorLoad walk result
+func (l *Load) hostLoadData(res snmphelper.SnmpOut) error {
+	cpuData, avg, err := calcCPUData(res, l.RoundMode)
+	if err != nil {
+		return fmt.Errorf("cpu data error: %v", err)
+	}
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(cpuData))
+	}
+
+	level, err := l.alarmLevel("cpu usage", int64(cpuData["load"]), l.Warn, l.Crit)
+	if err != nil {
+		return fmt.Errorf("alarm level error: %v", err)
+	}
+
+	l.addPerf("'cpu usage'", l.pct(avg, cpuData["load"]), "%", l.Warn, l.Crit, "0", "100")
+	l.addPerf("'cpu count'", fmt.Sprintf("%d", cpuData["cpuCnt"]), "", "", "", "", "")
+	l.addPerf("dummy", "0", "", "", "", "", "")
+	l.addMsg(level, fmt.Sprintf("%d CPUs; load %d%%", cpuData["cpuCnt"], cpuData["load"]), "")
+	l.normalized(cpuData["load"])
+	l.data.CPUCount = cpuData["cpuCnt"]
+
+	return nil
+}
+
+// Get load data using ssCpuIdle oid
+func (l *Load) cpuLoad() error {
+	// Do SNMP query
+	res, err := l.get([]string{ssCpuUser, ssCpuSystem, ssCpuRawIdle})
+	if err != nil {
+		return fmt.Errorf("snmp error: %v", err)
+	}
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(res))
+	}
+
+	d := map[string]int64{
+		"used": 100 - int64(res[ssCpuRawIdle].Integer),
+		"user": int64(res[ssCpuUser].Integer),
+		"sys":  int64(res[ssCpuSystem].Integer),
+	}
+
+	level, err := l.alarmLevel("cpu_prct_used", d["used"], l.Warn, l.Crit)
+	if err != nil {
+		return fmt.Errorf("alarm level error: %v", err)
+	}
+
+	l.addPerf("cpu_prct_used", fmt.Sprintf("%d", d["used"]), "%", l.Warn, l.Crit, "0", "100")
+	l.addPerf("cpu_prct_user", fmt.Sprintf("%d", d["user"]), "%", "", "", "0", "100")
+	l.addPerf("cpu_prct_system", fmt.Sprintf("%d", d["sys"]), "%", "", "", "0", "100")
+	l.addMsg(level, fmt.Sprintf("load %d%%", d["used"]), "")
+	l.addMsg(level, fmt.Sprintf("user %d%%", d["user"]), "")
+	l.addMsg(level, fmt.Sprintf("system %d%%", d["sys"]), "")
+	l.normalized(d["used"])
+
+	return nil
+}
+
+// Get load data using laLoadInt oid
+func (l *Load) sysLoad() error {
+	// Get processor count
+	pCnt, err := l.cpuCount()
+	if err != nil {
+		return err
+	}
+	l.data.CPUCount = int64(pCnt)
+
+	alarm := map[string]bool{"l1": true, "l5": true, "l15": true}
+	switch l.LaInterval {
+	case "", "all":
+	case "1", "5", "15":
+		alarm = map[string]bool{"l" + l.LaInterval: true}
+	default:
+		return fmt.Errorf("not valid loadavg interval - %s", l.LaInterval)
+	}
+
+	wl, cl, err := l.derivedLevels(loadavgDecs)
+	if err != nil {
+		return err
+	}
+
+	w1 := pCnt * wl[0]
+	c1 := pCnt * cl[0]
+	w5 := pCnt * wl[1]
+	c5 := pCnt * cl[1]
+	w15 := pCnt * wl[2]
+	c15 := pCnt * cl[2]
+
+	loads := map[string]map[string]string{
+		"l1": {
+			"oid":   laLoadInt + ".1",
+			"name":  "load_1_min",
+			"warn":  strconv.Itoa(w1),
+			"crit":  strconv.Itoa(c1),
+			"wReal": fmt.Sprintf("%.2f", float64(w1)/100),
+			"cReal": fmt.Sprintf("%.2f", float64(c1)/100),
+		},
+		"l5": {
+			"oid":   laLoadInt + ".2",
+			"name":  "load_5_min",
+			"warn":  strconv.Itoa(w5),
+			"crit":  strconv.Itoa(c5),
+			"wReal": fmt.Sprintf("%.2f", float64(w5)/100),
+			"cReal": fmt.Sprintf("%.2f", float64(c5)/100),
+		},
+		"l15": {
+			"oid":   laLoadInt + ".3",
+			"name":  "load_15_min",
+			"warn":  strconv.Itoa(w15),
+			"crit":  strconv.Itoa(c15),
+			"wReal": fmt.Sprintf("%.2f", float64(w15)/100),
+			"cReal": fmt.Sprintf("%.2f", float64(c15)/100),
+		},
+	}
+
+	// Do SNMP query
+	res, err := l.get([]string{loads["l1"]["oid"], loads["l5"]["oid"], loads["l15"]["oid"]})
+	if err != nil {
+		return fmt.Errorf("snmp error: %v", err)
+	}
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(res))
+	}
+
+	l.addMsg(0, fmt.Sprintf("%d CPUs", pCnt), "")
+
+	for _, p := range [3]string{"l1", "l5", "l15"} {
+		v := res[loads[p]["oid"]].Integer
+
+		// Intervals not selected by LaInterval are reported without alarm
+		level := 0
+		if alarm[p] {
+			level, err = l.alarmLevel(loads[p]["name"], v, loads[p]["warn"], loads[p]["crit"])
+			if err != nil {
+				return fmt.Errorf("alarm level error: %v", err)
+			}
+		}
+
+		vReal := fmt.Sprintf("%.2f", float64(v)/100)
+		l.addPerf(loads[p]["name"], vReal, "", loads[p]["wReal"], loads[p]["cReal"], "0", "")
+		l.addMsg(level, fmt.Sprintf("%s %s", p, vReal), "")
+
+		// 1 min load of all cores as percent
+		if p == "l1" {
+			l.normalized(roundVal(float64(v)/float64(pCnt), l.RoundMode))
+		}
+	}
+
+	return nil
+}
+
+// Get Juniper load data using jnxOperatingTable
+func (l *Load) jnxLoad() error {
+	// Find routing engines
+	res, err := l.walk(jnxOperatingDescr, true, true)
+	if err != nil {
+		return fmt.Errorf("snmp error: %v", err)
+	}
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(res))
+	}
+
+	// Junos Evolved has version suffix -EVO in sysDescr. Classic Junos is assumed on failure.
+	// SRX chassis cluster nodes are reported like Virtual Chassis members. sysDescr is
+	// queried only if descriptors are EVO style or duplicate routing engine names.
+	evo, srx := false, false
+	if jnxAmbiguous(res) {
+		d, err := l.get([]string{sysDescr})
+		if err != nil {
+			if l.Debug {
+				fmt.Printf("sysDescr query failed: %v\n", err)
+			}
+		} else {
+			u := strings.ToUpper(d[sysDescr].OctetString)
+			evo = strings.Contains(u, "-EVO") || strings.Contains(u, "EVOLVED")
+			srx = strings.Contains(u, "SRX")
+		}
+	}
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("junos evolved: %v, srx: %v\n", evo, srx)
+	}
+
+	re := make(map[string]string)
+	fabric := make(map[string]string)
+	for i, d := range res {
+		if evo && jnxEvoRe.MatchString(d.OctetString) {
+			re[i] = d.OctetString
+		} else if strings.Contains(strings.ToUpper(d.OctetString), strings.ToUpper("Routing Engine")) {
+			re[i] = d.OctetString
+		} else if l.IncludeFabric && jnxFabric.MatchString(d.OctetString) {
+			fabric[i] = d.OctetString
+		}
+	}
+	if srx {
+		jnxMemberNames(re, "node")
+	} else {
+		jnxMemberNames(re, "member")
+	}
+
+	// Component states are optional. Failed query leaves them unknown and
+	// routing engines unfiltered.
+	states := make(map[string]string)
+	if l.WithState || !l.IncludeOffline {
+		res, err := l.walk(jnxOperatingState, true, true)
+		if err != nil && l.Debug {
+			fmt.Printf("state query failed: %v\n", err)
+		}
+		for i, n := range re {
+			v, ok := res[i]
+			if !ok {
+				continue
+			}
+			if !l.IncludeOffline && jnxOfflineStates[v.Integer] {
+				// DEBUG
+				if l.Debug {
+					fmt.Printf("skipped offline routing engine %s, state %d\n", n, v.Integer)
+				}
+				delete(re, i)
+				continue
+			}
+			states[n] = jnxTransStates[v.Integer]
+		}
+	}
+
+	// Routing engines not queried before deadline are reported as unknown
+	loads := make(map[string]map[string]uint64)
+	temps := make(map[string]uint64)
+	buffers := make(map[string]uint64)
+	for i, n := range re {
+		if l.expired() {
+			continue
+		}
+
+		// Do SNMP query
+		o := []string{jnxOperatingCPU + "." + i, jnxOperating1MinLoadAvg + "." + i, jnxOperating5MinLoadAvg + "." + i}
+		res, err := l.get(o)
+		if err != nil {
+			if l.expired() {
+				continue
+			}
+			return fmt.Errorf("snmp error: %v", err)
+		}
+		// DEBUG
+		if l.Debug {
+			fmt.Printf("%# v\n", pretty.Formatter(res))
+		}
+
+		// Values are Gauge32 or Integer depending on platform. Non numeric
+		// values are left out and reported as missing.
+		d := make(map[string]uint64)
+		for k, o := range map[string]string{"util": jnxOperatingCPU, "load1": jnxOperating1MinLoadAvg, "load5": jnxOperating5MinLoadAvg} {
+			v, err := numValue(res, o+"."+i)
+			if err != nil || v < 0 {
+				// DEBUG
+				if l.Debug {
+					fmt.Printf("%s %s: %v\n", n, k, err)
+				}
+				continue
+			}
+			d[k] = uint64(v)
+		}
+
+		loads[n] = d
+
+		// Temperature is optional. Failed query leaves it out.
+		if l.WithTemp {
+			var v int64
+			res, err := l.get([]string{jnxOperatingTemp + "." + i})
+			if err == nil {
+				v, err = numValue(res, jnxOperatingTemp+"."+i)
+			}
+			if err == nil && v >= 0 {
+				temps[n] = uint64(v)
+			} else if l.Debug {
+				fmt.Printf("temperature query failed: %v\n", err)
+			}
+		}
+
+		// Buffer utilization is optional. Failed query leaves it out.
+		if l.WithBuffer {
+			var v int64
+			res, err := l.get([]string{jnxOperatingBuffer + "." + i})
+			if err == nil {
+				v, err = numValue(res, jnxOperatingBuffer+"."+i)
+			}
+			if err == nil && v >= 0 {
+				buffers[n] = uint64(v)
+			} else if l.Debug {
+				fmt.Printf("buffer query failed: %v\n", err)
+			}
+		}
+	}
+
+	cs := make(map[string]bool)
+	for _, n := range re {
+		cs[n] = true
+	}
+	cn := make([]string, 0, len(cs))
+	for k := range cs {
+		cn = append(cn, k)
+	}
+	sort.Strings(cn)
+
+	err = l.expectComponents(len(cn))
+	if err != nil {
+		return err
+	}
+
+	idx := make(map[string]string)
+	for i, n := range re {
+		idx[n] = i
+	}
+
+	// Diverged routing engine utilizations are reported without alarm
+	diverged := l.CrossCheck > 0 && l.jnxCrossCheck(re, cn, loads)
+
+	// Only primary routing engines are alarmed if any is found
+	primary := make(map[string]bool)
+	if l.PrimaryOnly || l.ReMode == "master" {
+		primary = l.jnxPrimary(re)
+	}
+
+	// With ReMode worst only busiest routing engine is alarmed, with average
+	// mean of all routing engines is alarmed instead of any of them
+	worst := ""
+	if l.ReMode == "worst" {
+		for _, n := range cn {
+			if v, ok := loads[n]["util"]; ok && (worst == "" || v > loads[worst]["util"]) {
+				worst = n
+			}
+		}
+	}
+
+	var utils, all []int64
+	for _, n := range cn {
+		l.addMsg(0, n, "")
+
+		if v, ok := loads[n]["util"]; ok {
+			all = append(all, int64(v))
+			if len(primary) > 0 && !primary[n] {
+				l.addPerf("'"+n+" util'", fmt.Sprintf("%d", v), "%", "", "", "0", "")
+				l.addMsg(0, fmt.Sprintf("util %d%% (backup)", v), "")
+			} else if diverged || l.Spu || !l.alarmed(n) || l.ReMode == "average" || (worst != "" && n != worst) {
+				l.addPerf("'"+n+" util'", fmt.Sprintf("%d", v), "%", "", "", "0", "")
+				l.addMsg(0, fmt.Sprintf("util %d%%", v), "")
+			} else {
+				utils = append(utils, int64(v))
+				w, c := l.componentLevels(n, idx[n])
+				level, err := l.alarmLevel(n+" util", int64(v), w, c)
+				if err != nil {
+					return fmt.Errorf("alarm level error: %v", err)
+				}
+				l.addPerf("'"+n+" util'", fmt.Sprintf("%d", v), "%", w, c, "0", "")
+				l.addMsg(level, fmt.Sprintf("util %d%%", v)+stateNote(level, states[n])+tempNote(level, temps, n), "")
+				l.component(n, int64(v))
+			}
+		} else {
+			l.addAbsent("util Na")
+		}
+
+		for _, t := range []string{"1", "5"} {
+			if v, ok := loads[n]["load"+t]; ok {
+				l.addPerf("'"+n+" load"+t+"'", fmt.Sprintf("%d", v), "%", "", "", "0", "")
+				l.addMsg(0, fmt.Sprintf("load%s %d%%", t, v), "")
+			} else {
+				l.addAbsent("load" + t + " Na")
+			}
+		}
+
+		if v, ok := temps[n]; ok {
+			l.addPerf("'"+n+" temp'", fmt.Sprintf("%d", v), "", "", "", "", "")
+		}
+
+		if v, ok := buffers[n]; ok {
+			l.addPerf("'"+n+" buffer'", fmt.Sprintf("%d", v), "%", "", "", "0", "100")
+		}
+	}
+
+	if l.ReMode == "average" && !l.Spu && !diverged && len(all) > 0 {
+		var sum int64
+		for _, v := range all {
+			sum += v
+		}
+		avg := roundVal(float64(sum)/float64(len(all)), l.RoundMode)
+
+		level, err := l.alarmLevel("re_average", avg, l.Warn, l.Crit)
+		if err != nil {
+			return fmt.Errorf("alarm level error: %v", err)
+		}
+		l.addPerf("re_average", fmt.Sprintf("%d", avg), "%", l.Warn, l.Crit, "0", "100")
+		l.addMsg(level, fmt.Sprintf("average util of %d routing engines %d%%", len(all), avg), "")
+		l.normalized(avg)
+		utils = all
+	}
+
+	l.imbalance(utils)
+
+	if len(fabric) > 0 {
+		err := l.jnxFabricLoad(fabric)
+		if err != nil {
+			return err
+		}
+	}
+
+	if l.Spu {
+		err := l.jnxSpuLoad()
+		if err != nil {
+			return err
+		}
+	}
+
+	// Canonical utilization of device is util of master or busiest routing engine
+	if l.ReAggregate != "" && !diverged && len(all) > 0 {
+		var v int64
+		for _, u := range all {
+			if u > v {
+				v = u
+			}
+		}
+		if l.ReAggregate == "master" && len(cn) > 1 {
+			if len(primary) == 0 {
+				primary = l.jnxPrimary(re)
+			}
+			for _, n := range cn {
+				if u, ok := loads[n]["util"]; ok && primary[n] {
+					v = int64(u)
+					break
+				}
+			}
+		}
+		l.norm, l.normSet = v, true
+	}
+
+	return nil
+}
+
+// Report Juniper SRX SPU cpu utilization using jnxJsSPUMonitoringObjectsTable.
+// Only busiest SPU is alarmed.
+func (l *Load) jnxSpuLoad() error {
+	res, err := l.walk(jnxJsSPUMonitoringCPUUsage, true, true)
+	if err != nil {
+		return fmt.Errorf("snmp error: %v", err)
+	}
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(res))
+	}
+
+	idx := make([]string, 0, len(res))
+	for i := range res {
+		idx = append(idx, i)
+	}
+	sort.Slice(idx, func(a, b int) bool {
+		x, _ := strconv.Atoi(idx[a])
+		y, _ := strconv.Atoi(idx[b])
+		return x < y
+	})
+
+	busiest, v := "", int64(0)
+	utils := make([]int64, 0, len(idx))
+	for _, i := range idx {
+		u, err := numValue(res, i)
+		if err != nil {
+			l.addAbsent("spu" + i + " util Na")
+			continue
+		}
+		utils = append(utils, u)
+		if busiest == "" || u > v {
+			busiest, v = i, u
+		}
+		l.addPerf("'spu"+i+" util'", fmt.Sprintf("%d", u), "%", l.Warn, l.Crit, "0", "100")
+		l.component("spu"+i, u)
+	}
+	if busiest == "" {
+		return nil
+	}
+
+	level, err := l.alarmLevel("spu busiest", v, l.Warn, l.Crit)
+	if err != nil {
+		return fmt.Errorf("alarm level error: %v", err)
+	}
+	l.addMsg(level, fmt.Sprintf("spu%s util %d%% (busiest of %d)", busiest, v, len(idx)), "")
+
+	l.imbalance(utils)
+
+	return nil
+}
+
+// Compare jnxOperatingCPU of only or primary routing engine with utilization
+// calculated from ssCpuIdle. Returns true if divergence exceeding Load.CrossCheck
+// was reported as unknown. Check is skipped if ssCpuIdle is not available.
+func (l *Load) jnxCrossCheck(re map[string]string, cn []string, loads map[string]map[string]uint64) bool {
+	n := ""
+	if len(cn) == 1 {
+		n = cn[0]
+	} else {
+		for p := range l.jnxPrimary(re) {
+			if n == "" || p < n {
+				n = p
+			}
+		}
+	}
+	util, ok := loads[n]["util"]
+	if !ok {
+		// DEBUG
+		if l.Debug {
+			fmt.Println("cross check skipped, routing engine not found")
+		}
+		return false
+	}
+
+	res, err := l.get([]string{ssCpuRawIdle})
+	if v, ok := res[ssCpuRawIdle]; err != nil || !ok || v.Vtype != "Integer" {
+		// DEBUG
+		if l.Debug {
+			fmt.Printf("cross check skipped, idle query failed: %v\n", err)
+		}
+		return false
+	}
+
+	used := 100 - res[ssCpuRawIdle].Integer
+	l.addPerf("'"+n+" util idle'", fmt.Sprintf("%d", used), "%", "", "", "0", "100")
+
+	d := used - int64(util)
+	if d < 0 {
+		d = -d
+	}
+	if d <= int64(l.CrossCheck) {
+		return false
+	}
+
+	l.addMsg(3, fmt.Sprintf("%s util %d%% differs from 100-idle %d%% by more than %d", n, util, used, l.CrossCheck), "")
+
+	return true
+}
+
+// Returns true if jnxOperatingDescr walk result has EVO style routing engine
+// descriptors (fe. "RE0") or duplicate classic routing engine descriptors
+func jnxAmbiguous(res snmphelper.SnmpOut) bool {
+	seen := make(map[string]bool)
+	for _, d := range res {
+		n := d.OctetString
+		classic := strings.Contains(strings.ToUpper(n), "ROUTING ENGINE")
+		if (!classic && jnxEvoRe.MatchString(n)) || (classic && seen[n]) {
+			return true
+		}
+		seen[n] = true
+	}
+
+	return false
+}
+
+// Prefix duplicate routing engine descriptors with Virtual Chassis member or SRX
+// cluster node number. Number is first level index of jnxOperatingTable index minus one.
+func jnxMemberNames(re map[string]string, prefix string) {
+	cnt := make(map[string]int)
+	for _, n := range re {
+		cnt[n]++
+	}
+
+	for i, n := range re {
+		if cnt[n] < 2 {
+			continue
+		}
+
+		idx := strings.Split(i, ".")
+		if len(idx) > 1 {
+			if l1, err := strconv.Atoi(idx[1]); err == nil {
+				re[i] = fmt.Sprintf("%s%d %s", prefix, l1-1, n)
+				continue
+			}
+		}
+		re[i] = n + " " + i
+	}
+}
+
+// Returns names of routing engines in master(2) jnxRedundancyState.
+// Failed query returns empty map.
+func (l *Load) jnxPrimary(re map[string]string) map[string]bool {
+	primary := make(map[string]bool)
+
+	res, err := l.walk(jnxRedundancyState, true, true)
+	if err != nil {
+		// DEBUG
+		if l.Debug {
+			fmt.Printf("redundancy state query failed: %v\n", err)
+		}
+		return primary
+	}
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(res))
+	}
+
+	for i, n := range re {
+		if v, ok := res[i]; ok && v.Integer == 2 {
+			primary[n] = true
+		}
+	}
+
+	return primary
+}
+
+// Report Juniper switch fabric board cpu as informational data
+func (l *Load) jnxFabricLoad(fabric map[string]string) error {
+	o := make([]string, 0, len(fabric))
+	for i := range fabric {
+		o = append(o, jnxOperatingCPU+"."+i)
+	}
+
+	res, err := l.get(o)
+	if err != nil {
+		return fmt.Errorf("snmp error: %v", err)
+	}
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(res))
+	}
+
+	utils := make(map[string]uint64)
+	for i, n := range fabric {
+		if v, err := numValue(res, jnxOperatingCPU+"."+i); err == nil && v >= 0 {
+			utils[n] = uint64(v)
+		}
+	}
+
+	fn := make([]string, 0, len(utils))
+	for k := range utils {
+		fn = append(fn, k)
+	}
+	sort.Strings(fn)
+
+	for _, n := range fn {
+		l.addPerf("'"+n+" util'", fmt.Sprintf("%d", utils[n]), "%", "", "", "0", "")
+		l.addMsg(0, fmt.Sprintf("%s util %d%%", n, utils[n]), "")
+	}
+
+	return nil
+}
+
+// Get Cisco load data using ciscoProcessMIB
+func (l *Load) ciscoLoad() error {
+	// Find CPU entity id-s
+	res, err := l.walk(cpmCPUTotalPhysicalIndex, true, true)
+	if err != nil {
+		return fmt.Errorf("snmp error: %v", err)
+	}
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(res))
+	}
+
+	names := make(map[string]string)
+	cpuIDs := make(map[string]int64)
+	for i, d := range res {
+		if d.Integer == 0 {
+			names[i] = "CPU0"
+			continue
+		}
+		cpuIDs[i] = d.Integer
+	}
+
+	// Find entity names
+	eo := make([]string, len(cpuIDs))
+	i := 0
+	for _, v := range cpuIDs {
+		eo[i] = fmt.Sprintf("%s.%d", entPhysicalName, v)
+		i++
+	}
+
+	res, err = l.get(eo)
+	if err != nil && !l.expired() {
+		return fmt.Errorf("snmp error: %v", err)
+	}
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(res))
+	}
+
+	for idx, eidx := range cpuIDs {
+		oid := fmt.Sprintf("%s.%d", entPhysicalName, eidx)
+		if res[oid].OctetString != "" {
+			names[idx] = res[oid].OctetString
+		} else if l.expired() {
+			names[idx] = "CPU" + idx
+		}
+	}
+
+	// Stack member lookup walks whole entity tables and is done only on request
+	if l.StackNames && l.Ctype == "cisco" && len(cpuIDs) > 1 && !l.expired() {
+		l.stackMemberNames(names, cpuIDs)
+	}
+
+	// Module states are optional. Failed query leaves them unknown.
+	states := make(map[string]string)
+	if l.WithState {
+		res, err := l.walk(cefcModuleOperStatus, true, true)
+		if err != nil && l.Debug {
+			fmt.Printf("state query failed: %v\n", err)
+		}
+		for idx, eidx := range cpuIDs {
+			if v, ok := res[strconv.FormatInt(eidx, 10)]; ok {
+				states[names[idx]] = cefcTransStates[v.Integer]
+			}
+		}
+	}
+
+	// Get CPU load data
+	lo := make([]string, 2*len(names))
+	i = 0
+	for idx := range names {
+		lo[i] = cpmCPUTotal1minRev + "." + idx
+		i++
+		lo[i] = cpmCPUTotal5minRev + "." + idx
+		i++
+	}
+
+	if l.Deadline > 0 {
+		// Query CPUs one by one to keep data gathered before deadline
+		res = snmphelper.SnmpOut{}
+		for j := 0; j < len(lo) && !l.expired(); j += 2 {
+			r, err := l.get(lo[j : j+2])
+			if err != nil {
+				if l.expired() {
+					break
+				}
+				return fmt.Errorf("snmp error: %v", err)
+			}
+			for k, v := range r {
+				res[k] = v
+			}
+		}
+	} else {
+		res, err = l.get(lo)
+		if err != nil {
+			return fmt.Errorf("snmp error: %v", err)
+		}
+	}
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(res))
+	}
+
+	loads := make(map[string]map[string]uint64)
+	for idx, n := range names {
+		l1mo := cpmCPUTotal1minRev + "." + idx
+		l5mo := cpmCPUTotal5minRev + "." + idx
+
+		d := make(map[string]uint64)
+		if v, ok := res[l1mo]; ok {
+			d["l1m"] = v.Gauge32
+		}
+		if v, ok := res[l5mo]; ok {
+			d["l5m"] = v.Gauge32
+		}
+		loads[n] = d
+	}
+
+	wl, cl, err := l.derivedLevels(ciscoDecs)
+	if err != nil {
+		return err
+	}
+
+	// Alarm levels for 5 min values
+	w5m := strconv.Itoa(wl[1])
+	c5m := strconv.Itoa(cl[1])
+
+	cn := make([]string, len(loads))
+	i = 0
+	for k := range loads {
+		cn[i] = k
+		i++
+	}
+	sort.Strings(cn)
+
+	err = l.expectComponents(len(cn))
+	if err != nil {
+		return err
+	}
+
+	// IOS-XR and cBR-8 line card cpus are informational if route processors
+	// or supervisors are found. They are reported after control plane cpus.
+	rp := make(map[string]bool)
+	if re, ok := controlCPU[l.Ctype]; ok {
+		for _, n := range cn {
+			if re.MatchString(n) {
+				rp[n] = true
+			}
+		}
+		sort.SliceStable(cn, func(i, j int) bool { return rp[cn[i]] && !rp[cn[j]] })
+	}
+
+	idx := make(map[string]string)
+	for i, n := range names {
+		idx[n] = i
+	}
+
+	// Process table is queried only once when first alarmed cpu is found
+	var procs map[string]string
+	procNote := func(level int, n string) string {
+		if !l.CPUProcess || level == 0 {
+			return ""
+		}
+		if procs == nil {
+			procs = l.topProcesses()
+		}
+		if p, ok := procs[idx[n]]; ok {
+			return fmt.Sprintf(" (top %s)", p)
+		}
+		return ""
+	}
+
+	// Wireless control processes are reported after cpu which was high in any interval
+	var wlc map[string][]string
+	wlcNote := func(n string) {
+		if wlc == nil {
+			wlc = l.wlcProcesses()
+		}
+		if p, ok := wlc[idx[n]]; ok {
+			l.addMsg(0, "top wlc processes "+strings.Join(p, ", "), "")
+		}
+	}
+
+	var utils []int64
+	for _, n := range cn {
+		info := len(rp) > 0 && !rp[n]
+		if info && l.Ctype == "cmts" {
+			l.addMsg(0, n+" (line card)", "")
+		} else {
+			l.addMsg(0, n, "")
+		}
+		im := &intervalMsgs{l: l}
+		noted, high := false, false
+		info = info || !l.alarmed(n)
+		w1m, c1m, w5, c5 := l.Warn, l.Crit, w5m, c5m
+		if w, c := l.componentLevels(n, idx[n]); w != l.Warn || c != l.Crit {
+			wl, cl, err := decreasedLevels(w, c, ciscoDecs)
+			if err != nil {
+				return err
+			}
+			w1m, c1m, w5, c5 = w, c, strconv.Itoa(wl[1]), strconv.Itoa(cl[1])
+		}
+		if info {
+			w1m, c1m, w5, c5 = "", "", "", ""
+		}
+
+		if v, ok := loads[n]["l1m"]; ok {
+			level := 0
+			if !info {
+				utils = append(utils, int64(v))
+				level, err = l.alarmLevel(n+" 1min", int64(v), w1m, c1m)
+				if err != nil {
+					return fmt.Errorf("alarm level error: %v", err)
+				}
+				l.component(n, int64(v))
+			}
+			l.addPerf("'"+n+" 1min'", fmt.Sprintf("%d", v), "%", w1m, c1m, "0", "")
+			high = high || level > 0
+			note := procNote(level, n)
+			noted = note != ""
+			im.add(level, fmt.Sprintf("1m %d%%", v)+stateNote(level, states[n])+note)
+		} else {
+			l.addAbsent("1m Na")
+		}
+
+		if v, ok := loads[n]["l5m"]; ok {
+			level := 0
+			if !info {
+				level, err = l.alarmLevel(n+" 5min", int64(v), w5, c5)
+				if err != nil {
+					return fmt.Errorf("alarm level error: %v", err)
+				}
+			}
+			l.addPerf("'"+n+" 5min'", fmt.Sprintf("%d", v), "%", w5, c5, "0", "")
+			high = high || level > 0
+			note := ""
+			if !noted {
+				note = procNote(level, n)
+			}
+			im.add(level, fmt.Sprintf("5m %d%%", v)+stateNote(level, states[n])+note)
+		} else {
+			l.addAbsent("5m Na")
+		}
+		im.flush()
+		if l.WlcProcess && high {
+			wlcNote(n)
+		}
+
+		l.addPerf("dummy", "0", "", "", "", "", "")
+	}
+	l.imbalance(utils)
+
+	return nil
+}
+
+// Returns top cpu consuming process with its 5 min utilization by cpmCPUTotalIndex.
+// Platforms without process table or extended utilization data return empty map.
+func (l *Load) topProcesses() map[string]string {
+	out := make(map[string]string)
+
+	utils, err := l.walk(cpmProcExtUtil5MinRev, true, true)
+	if err != nil {
+		if l.Debug {
+			fmt.Printf("process query failed: %v\n", err)
+		}
+		return out
+	}
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(utils))
+	}
+
+	pnames, err := l.walk(cpmProcessName, true, true)
+	if err != nil && l.Debug {
+		fmt.Printf("process name query failed: %v\n", err)
+	}
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(pnames))
+	}
+
+	// Index is <cpmCPUTotalIndex>.<cpmProcessPID>
+	top := make(map[string]string)
+	for k, v := range utils {
+		s := strings.SplitN(k, ".", 2)
+		if len(s) != 2 {
+			continue
+		}
+		if t, ok := top[s[0]]; !ok || v.Gauge32 > utils[t].Gauge32 ||
+			(v.Gauge32 == utils[t].Gauge32 && k < t) {
+			top[s[0]] = k
+		}
+	}
+
+	for cpu, k := range top {
+		n := pnames[k].OctetString
+		if n == "" {
+			n = "pid " + strings.SplitN(k, ".", 2)[1]
+		}
+		out[cpu] = fmt.Sprintf("%s %d%%", n, utils[k].Gauge32)
+	}
+
+	return out
+}
+
+// Returns up to three busiest wireless control processes with their 1 min utilization
+// by cpmCPUTotalIndex. Platforms without process table return empty map.
+func (l *Load) wlcProcesses() map[string][]string {
+	out := make(map[string][]string)
+
+	pnames, err := l.walk(cpmProcessName, true, true)
+	if err != nil {
+		if l.Debug {
+			fmt.Printf("process name query failed: %v\n", err)
+		}
+		return out
+	}
+
+	utils, err := l.walk(cpmProcExtUtil1MinRev, true, true)
+	if err != nil {
+		if l.Debug {
+			fmt.Printf("process query failed: %v\n", err)
+		}
+		return out
+	}
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(utils))
+	}
+
+	// Index is <cpmCPUTotalIndex>.<cpmProcessPID>
+	procs := make(map[string][]string)
+	for k, v := range pnames {
+		s := strings.SplitN(k, ".", 2)
+		if _, ok := utils[k]; ok && len(s) == 2 && wlcProc.MatchString(v.OctetString) {
+			procs[s[0]] = append(procs[s[0]], k)
+		}
+	}
+
+	for cpu, ks := range procs {
+		sort.Slice(ks, func(i, j int) bool {
+			a, b := utils[ks[i]].Gauge32, utils[ks[j]].Gauge32
+			return a > b || (a == b && ks[i] < ks[j])
+		})
+		if len(ks) > 3 {
+			ks = ks[:3]
+		}
+		for _, k := range ks {
+			out[cpu] = append(out[cpu], fmt.Sprintf("%s %d%%", pnames[k].OctetString, utils[k].Gauge32))
+		}
+	}
+
+	return out
+}
+
+// Interval messages of component. With Load.IntervalMode worst messages are
+// consolidated to one message with level of worst interval.
+type intervalMsgs struct {
+	l     *Load
+	level int
+	parts []string
+}
+
+// Add interval message
+func (m *intervalMsgs) add(level int, short string) {
+	if m.l.IntervalMode != "worst" {
+		m.l.addMsg(level, short, "")
+		return
+	}
+
+	if level > m.level {
+		m.level = level
+	}
+	m.parts = append(m.parts, short)
+}
+
+// Add consolidated message of collected intervals
+func (m *intervalMsgs) flush() {
+	if len(m.parts) > 0 {
+		m.l.addMsg(m.level, strings.Join(m.parts, ", "), "")
+	}
+	m.parts = nil
+}
+
+// Name Catalyst stack member cpus by switch number (fe. "switch2"). Cpu entity is
+// followed up through entPhysicalContainedIn to member chassis contained in stack
+// entity. Member number is its entPhysicalParentRelPos. Names are left unchanged
+// if any cpu can not be mapped to a distinct member.
+func (l *Load) stackMemberNames(names map[string]string, cpuIDs map[string]int64) {
+	ent := make(map[string]snmphelper.SnmpOut)
+	for _, o := range []string{entPhysicalContainedIn, entPhysicalClass, entPhysicalParentRelPos} {
+		res, err := l.walk(o, true, true)
+		if err != nil {
+			// DEBUG
+			if l.Debug {
+				fmt.Printf("stack member query failed: %v\n", err)
+			}
+			return
+		}
+		ent[o] = res
+	}
+
+	members := make(map[string]string)
+	seen := make(map[string]bool)
+	for idx, eidx := range cpuIDs {
+		cur := strconv.FormatInt(eidx, 10)
+		// Limit depth in case of containment loop
+		for i := 0; i < 16 && members[idx] == ""; i++ {
+			p := ent[entPhysicalContainedIn][cur].Integer
+			if p == 0 {
+				break
+			}
+			pi := strconv.FormatInt(p, 10)
+			if ent[entPhysicalClass][pi].Integer == entClassStack {
+				members[idx] = fmt.Sprintf("switch%d", ent[entPhysicalParentRelPos][cur].Integer)
+			}
+			cur = pi
+		}
+		if members[idx] == "" || seen[members[idx]] {
+			return
+		}
+		seen[members[idx]] = true
+	}
+
+	for idx, n := range members {
+		names[idx] = n
+	}
+}
+
+// Get Cisco Small Business switch load data using rlCpuUtilDuringLast* oids.
+// 1 minute value drives alarm level, 1 second and 5 minute values are informational.
+func (l *Load) ciscoSbLoad() error {
+	wl, cl, err := l.derivedLevels(ciscoDecs)
+	if err != nil {
+		return err
+	}
+
+	// Alarm levels for 5 min value
+	w5m := strconv.Itoa(wl[1])
+	c5m := strconv.Itoa(cl[1])
+
+	// Do SNMP query
+	res, err := l.get([]string{rlCpuUtilDuringLastSecond, rlCpuUtilDuringLastMinute, rlCpuUtilDuringLast5Minutes})
+	if err != nil {
+		return fmt.Errorf("snmp error: %v", err)
+	}
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(res))
+	}
+
+	u1s := res[rlCpuUtilDuringLastSecond].Integer
+	u1m := res[rlCpuUtilDuringLastMinute].Integer
+	u5m := res[rlCpuUtilDuringLast5Minutes].Integer
+
+	level, err := l.alarmLevel("usage_1_min", u1m, l.Warn, l.Crit)
+	if err != nil {
+		return fmt.Errorf("alarm level error: %v", err)
+	}
+
+	l.addPerf("usage_1_min", fmt.Sprintf("%d", u1m), "%", l.Warn, l.Crit, "0", "100")
+	l.addPerf("usage_1_sec", fmt.Sprintf("%d", u1s), "%", "", "", "0", "100")
+	l.addPerf("usage_5_min", fmt.Sprintf("%d", u5m), "%", w5m, c5m, "0", "100")
+	l.addMsg(level, fmt.Sprintf("1m %d%%", u1m), "")
+	l.addMsg(0, fmt.Sprintf("1s %d%%", u1s), "")
+	l.addMsg(0, fmt.Sprintf("5m %d%%", u5m), "")
+	l.normalized(u1m)
+
+	return nil
+}
+
+// Get load data using tmnxSysCpuMonCpuIdle oid
+func (l *Load) timetraLoad() error {
+	wl, cl, err := l.derivedLevels(timetraDecs)
+	if err != nil {
+		return err
+	}
+
+	w1 := wl[0]
+	c1 := cl[0]
+	w60 := wl[1]
+	c60 := cl[1]
+	w300 := wl[2]
+	c300 := cl[2]
+
+	idle := map[string]map[string]string{
+		"u1": {
+			"oid":   tmnxSysCpuMonCpuIdle + ".1",
+			"name":  "usage_1_sec",
+			"warn":  strconv.Itoa(w1 * 100),
+			"crit":  strconv.Itoa(c1 * 100),
+			"wReal": l.Warn,
+			"cReal": l.Crit,
+		},
+		"u60": {
+			"oid":   tmnxSysCpuMonCpuIdle + ".60",
+			"name":  "usage_60_sec",
+			"warn":  strconv.Itoa(w60 * 100),
+			"crit":  strconv.Itoa(c60 * 100),
+			"wReal": fmt.Sprintf("%d", w60),
+			"cReal": fmt.Sprintf("%d", c60),
+		},
+		"u300": {
+			"oid":   tmnxSysCpuMonCpuIdle + ".300",
+			"name":  "usage_300_sec",
+			"warn":  strconv.Itoa(w300 * 100),
+			"crit":  strconv.Itoa(c300 * 100),
+			"wReal": fmt.Sprintf("%d", w300),
+			"cReal": fmt.Sprintf("%d", c300),
+		},
+	}
+
+	// Do SNMP query
+	res, err := l.get([]string{idle["u1"]["oid"], idle["u60"]["oid"], idle["u300"]["oid"]})
+	if err != nil {
+		return fmt.Errorf("snmp error: %v", err)
+	}
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(res))
+	}
+
+	for _, p := range [3]string{"u1", "u60", "u300"} {
+		v := 10000 - int64(res[idle[p]["oid"]].Gauge32)
+
+		level, err := l.alarmLevel(idle[p]["name"], v, idle[p]["warn"], idle[p]["crit"])
+		if err != nil {
+			return fmt.Errorf("alarm level error: %v", err)
+		}
+
+		vReal := fmt.Sprintf("%.2f", float64(v)/100)
+		l.addPerf(idle[p]["name"], vReal, "", idle[p]["wReal"], idle[p]["cReal"], "0", "")
+		l.addMsg(level, fmt.Sprintf("%s %s", p, vReal), "")
+
+		if p == "u1" {
+			l.normalized(roundVal(float64(v)/100, l.RoundMode))
+		}
+	}
+
+	return nil
+}
+
+// Get load data using rcDeviceStsCpuUsagePercent oid
+func (l *Load) ruggedSwLoad() error {
+	// Do SNMP query
+	res, err := l.get([]string{rcDeviceStsCpuUsagePercent})
+	if err != nil {
+		return fmt.Errorf("snmp error: %v", err)
+	}
+	if l.SampleTwice {
+		res, err = l.secondSample(res, func() (snmphelper.SnmpOut, error) {
+			return l.get([]string{rcDeviceStsCpuUsagePercent})
+		})
+		if err != nil {
+			return fmt.Errorf("snmp error: %v", err)
+		}
+	}
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(res))
+	}
+
+	u := int64(res[rcDeviceStsCpuUsagePercent].Integer)
+
+	level, err := l.alarmLevel("cpu_usage", u, l.Warn, l.Crit)
+	if err != nil {
+		return fmt.Errorf("alarm level error: %v", err)
+	}
+
+	l.addPerf("cpu_usage", fmt.Sprintf("%d", u), "%", l.Warn, l.Crit, "0", "100")
+	l.addPerf("dummy1", "0", "", "", "", "", "")
+	l.addPerf("dummy2", "0", "", "", "", "", "")
+	l.addMsg(level, fmt.Sprintf("usage %d%%", u), "")
+	l.normalized(u)
+
+	return nil
+}
+
+// Get Moxa load data using cpuLoading5s cpuLoading30s cpuLoading300s oids
+func (l *Load) moxaSwLoad() error {
+	// Get sysobjectid
+	res, err := l.get([]string{sysObjectID})
+	if err != nil {
+		return fmt.Errorf("snmp error: %v", err)
+	}
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(res))
+	}
+
+	soi := res[sysObjectID].ObjectIdentifier
+
+	sfx, err := parseOidSuffixes(l.MoxaSuffix, moxaSuffixes)
+	if err != nil {
+		return fmt.Errorf("moxa suffix error: %v", err)
+	}
+
+	ol5 := soi + sfx[0]
+	ol30 := soi + sfx[1]
+	ol300 := soi + sfx[2]
+
+	res, err = l.get([]string{ol5, ol30, ol300})
+	if err != nil {
+		return fmt.Errorf("snmp error: %v", err)
+	}
+	if l.SampleTwice {
+		res, err = l.secondSample(res, func() (snmphelper.SnmpOut, error) {
+			return l.get([]string{ol5, ol30, ol300})
+		})
+		if err != nil {
+			return fmt.Errorf("snmp error: %v", err)
+		}
+	}
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(res))
+	}
+
+	l5 := int64(res[ol5].Integer)
+	l30 := int64(res[ol30].Integer)
+	l300 := int64(res[ol300].Integer)
+
+	wl, cl, err := l.derivedLevels(moxaDecs)
+	if err != nil {
+		return err
+	}
+
+	// Alarm levels for 30s and 300s values
+	w30s := strconv.Itoa(wl[1])
+	c30s := strconv.Itoa(cl[1])
+	w300s := strconv.Itoa(wl[2])
+	c300s := strconv.Itoa(cl[2])
+
+	im := &intervalMsgs{l: l}
+	level, err := l.alarmLevel("usage_5s", l5, l.Warn, l.Crit)
+	if err != nil {
+		return fmt.Errorf("alarm level error: %v", err)
+	}
+	l.addPerf("usage_5s", fmt.Sprintf("%d", l5), "%", l.Warn, l.Crit, "0", "100")
+	im.add(level, fmt.Sprintf("usage 5s %d%%", l5))
+	l.normalized(l5)
+
+	level, err = l.alarmLevel("usage_30s", l30, w30s, c30s)
+	if err != nil {
+		return fmt.Errorf("alarm level error: %v", err)
+	}
+	l.addPerf("usage_30s", fmt.Sprintf("%d", l30), "%", w30s, c30s, "0", "100")
+	im.add(level, fmt.Sprintf("30s %d%%", l30))
+
+	level, err = l.alarmLevel("usage_300s", l300, w300s, c300s)
+	if err != nil {
+		return fmt.Errorf("alarm level error: %v", err)
+	}
+	l.addPerf("usage_300s", fmt.Sprintf("%d", l300), "%", w300s, c300s, "0", "100")
+	im.add(level, fmt.Sprintf("300s %d%%", l300))
+	im.flush()
+
+	return nil
+}
+
+// Returns first sample merged with second sample taken after Load.SampleInterval.
+// Integer values are merged by Load.SampleMode (avg|max). Values missing from
+// second sample are kept.
+func (l *Load) secondSample(first snmphelper.SnmpOut, query func() (snmphelper.SnmpOut, error)) (snmphelper.SnmpOut, error) {
+	time.Sleep(l.SampleInterval)
+	second, err := query()
+	if err != nil {
+		return nil, err
+	}
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("first sample: %# v\n", pretty.Formatter(first))
+	}
+
+	for k, v := range first {
+		s, ok := second[k]
+		if !ok {
+			continue
+		}
+		if l.SampleMode == "max" {
+			if s.Integer > v.Integer {
+				v.Integer = s.Integer
+			}
+		} else {
+			v.Integer = roundVal(float64(v.Integer+s.Integer)/2, l.RoundMode)
+		}
+		first[k] = v
+	}
+
+	return first, nil
+}
+
+// Do SNMP get. Failed query is retried Load.Retries times with exponential backoff.
+// Oids are requested in chunks of Load.MaxOids and results merged.
+func (l *Load) get(oids []string) (snmphelper.SnmpOut, error) {
+	size := len(oids)
+	if l.MaxOids > 0 && l.MaxOids < size {
+		size = l.MaxOids
+	}
+
+	res := make(snmphelper.SnmpOut)
+	for i := 0; i < len(oids); i += size {
+		end := i + size
+		if end > len(oids) {
+			end = len(oids)
+		}
+
+		var out snmphelper.SnmpOut
+		err := l.retry(func() error {
+			var err error
+			out, err = l.Sess.Get(oids[i:end])
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range out {
+			res[k] = v
+		}
+	}
+
+	return res, nil
+}
+
+// Do SNMP walk. Failed query is retried Load.Retries times with exponential backoff.
+// Empty walk is retried Load.RetryEmpty more times with emptyWalkDelay.
+func (l *Load) walk(oid string, bulk bool, stripoid bool) (snmphelper.SnmpOut, error) {
+	var res snmphelper.SnmpOut
+	query := func() error {
+		var err error
+		res, err = l.Sess.Walk(oid, bulk, stripoid)
+		return err
+	}
+
+	err := l.retry(query)
+	for i := 0; emptyWalk(err) && i < l.RetryEmpty && !l.expired(); i++ {
+		// DEBUG
+		if l.Debug {
+			fmt.Printf("empty walk retry %d in %v: %v\n", i+1, emptyWalkDelay, err)
+		}
+		time.Sleep(emptyWalkDelay)
+		err = query()
+	}
+
+	return res, err
+}
+
+// Returns true if walk error is caused by empty result
+func emptyWalk(err error) bool {
+	return err != nil && strings.HasSuffix(err.Error(), "no results")
+}
+
+// Runs query until it succeeds or retries are exhausted
+func (l *Load) retry(query func() error) error {
+	delay := l.RetryBackoff
+	err := query()
+	for i := 0; err != nil && i < l.Retries && !l.expired(); i++ {
+		// DEBUG
+		if l.Debug {
+			fmt.Printf("retry %d in %v after error: %v\n", i+1, delay, err)
+		}
+		time.Sleep(delay)
+		delay *= 2
+		err = query()
+	}
+
+	return err
+}
+
+// Returns alarm level of named value. If alarm is calculated from canonical cpu utilization,
+// levels are only validated and value is reported as OK.
+func (l *Load) alarmLevel(name string, v int64, w, c string) (int, error) {
+	if l.AlarmOnNormalized {
+		_, err := icingahelper.NewCheck("").AlarmLevel(v, w, c)
+		return 0, err
+	}
+
+	return l.checkLevel(name, v, w, c)
+}
+
+// Returns alarm level of named value and raises check return value. Previous
+// higher level of the same name is kept while value is within Load.Deadband of
+// its level. Level is capped to Load.SuppressLevel if device booted within
+// Load.SuppressAfterBoot.
+func (l *Load) checkLevel(name string, v int64, w, c string) (int, error) {
+	level, err := icingahelper.NewCheck("").AlarmLevel(v, w, c)
+	if err != nil {
+		return level, err
+	}
+
+	// Levels are matched to previous check by component name or perfdata label
+	if l.bandCur != nil {
+		if p, ok := l.bandPrev[name]; ok && p > level && p < 3 {
+			held, _ := icingahelper.NewCheck("").AlarmLevel(v+int64(l.Deadband), w, c)
+			if held > level {
+				level = held
+				if p < level {
+					level = p
+				}
+			}
+		}
+		l.bandCur[name] = level
+	}
+
+	if max := suppressLevels[l.SuppressLevel]; l.booting && level > max {
+		level = max
+		l.suppressed = true
+	}
+	// Levels of held components and intervals are replaced by level of aggregated max
+	if !l.holding || l.keeping {
+		l.setLevel(level)
+	}
+
+	return level, nil
+}
+
+// Report device uptime and enable capping of cpu alarms if device booted within
+// Load.SuppressAfterBoot. Failed sysUpTime query leaves alarms unchanged.
+func (l *Load) bootWindow() {
+	res, err := l.get([]string{sysUpTime})
+	if err != nil {
+		// DEBUG
+		if l.Debug {
+			fmt.Printf("uptime query failed: %v\n", err)
+		}
+		return
+	}
+
+	// sysUpTime is in hundredths of a second
+	up := res[sysUpTime].TimeTicks / 100
+	l.addPerf("uptime", fmt.Sprintf("%d", up), "s", "", "", "0", "")
+	l.booting = up < uint64(l.SuppressAfterBoot)
+}
+
+// Set canonical 0-100 cpu utilization. Highest submitted value is kept.
+func (l *Load) normalized(v int64) {
+	if v > 100 {
+		v = 100
+	}
+	if !l.normSet || v > l.norm {
+		l.norm = v
+		l.normSet = true
+	}
+}
+
+// Returns true if snmp time budget of check is used up
+func (l *Load) expired() bool {
+	return !l.deadline.IsZero() && time.Now().After(l.deadline)
+}
+
+// Add performance data. Label is prefixed with Load.Prefix if set.
+func (l *Load) addPerf(label, value, unit, warn, crit, min, max string) {
+	if l.holding {
+		l.held = append(l.held, output{
+			perf: true, keep: l.keeping, label: label, value: value, unit: unit, warn: warn, crit: crit, min: min, max: max,
+		})
+		return
+	}
+
+	if n, ok := strictPerf(label, value); ok {
+		l.metrics = append(l.metrics, Metric{Label: strings.Trim(n, "'"), Value: value, Unit: unit})
+	}
+
+	if l.Prefix != "" {
+		label = "'" + l.Prefix + " " + strings.Trim(label, "'") + "'"
+	}
+
+	if l.LabelStyle == "snake" {
+		label = snakeLabel(label)
+	}
+
+	if l.StrictPerf {
+		var ok bool
+		label, ok = strictPerf(label, value)
+		if !ok {
+			// DEBUG
+			if l.Debug {
+				fmt.Printf("perfdata dropped: %s=%s\n", label, value)
+			}
+			return
+		}
+	}
+
+	if l.Buffered {
+		l.buffer = append(l.buffer, output{
+			perf: true, label: label, value: value, unit: unit, warn: warn, crit: crit, min: min, max: max,
+		})
+		return
+	}
+
+	l.Check.AddPerfData(label, value, unit, warn, crit, min, max)
+}
+
+// Report buffered output to check c and raise its return value to level of
+// Load.Check. Load.Check is replaced by c.
+func (l *Load) Flush(c *icingahelper.IcingaCheck) {
+	for _, o := range l.buffer {
+		if o.perf {
+			c.AddPerfData(o.label, o.value, o.unit, o.warn, o.crit, o.min, o.max)
+		} else {
+			c.AddMsg(o.level, o.short, o.long)
+		}
+	}
+	l.buffer = nil
+
+	level := l.Check.RetVal()
+	l.Check = c
+	l.Buffered = false
+	l.setLevel(level)
+}
+
+// Returns performance data values reported by check
+func (l *Load) Metrics() []Metric {
+	return l.metrics
+}
+
+// Returns canonical 0-100 cpu utilization and false if check type did not set it
+func (l *Load) Utilization() (int64, bool) {
+	return l.norm, l.normSet
+}
+
+// Returns perfdata label in lower case with runs of other characters than
+// letters and digits replaced by underscore (fe. 'Routing Engine 0 util' -> routing_engine_0_util)
+func snakeLabel(label string) string {
+	s := strings.ToLower(strings.Trim(label, "'"))
+	s = strings.Trim(snakeSep.ReplaceAllString(s, "_"), "_")
+	if s == "" {
+		return label
+	}
+
+	return s
+}
+
+// Returns label quoted only if it contains spaces and false if perfdata entry
+// is padding or not valid.
+func strictPerf(label, value string) (string, bool) {
+	label = strings.Trim(label, "'")
+	label = strings.NewReplacer("'", "_", "=", "_").Replace(label)
+
+	if label == "" || strings.HasPrefix(label, "dummy") {
+		return label, false
+	}
+
+	if _, err := strconv.ParseFloat(value, 64); err != nil {
+		return label, false
+	}
+
+	if strings.Contains(label, " ") {
+		label = "'" + label + "'"
+	}
+
+	return label, true
+}
+
+// Add check message. Short message is prefixed with Load.Prefix if set.
+// Messages are only collected for template if Load.Template is set.
+func (l *Load) addMsg(level int, short, long string) {
+	if l.holding {
+		l.held = append(l.held, output{keep: l.keeping, level: level, short: short, long: long})
+		return
+	}
+
+	if l.Template != "" {
+		l.data.Messages = append(l.data.Messages, short)
+		return
+	}
+
+	if l.Prefix != "" {
+		short = l.Prefix + ": " + short
+	}
+
+	if l.Buffered {
+		l.buffer = append(l.buffer, output{level: level, short: short, long: long})
+		return
+	}
+
+	l.Check.AddMsg(level, short, long)
+}
+
+// Add message of missing cpu data with level set by Load.UnknownAs.
+// Warning and critical levels raise check return value.
+func (l *Load) addAbsent(short string) {
+	level := absentLevels[l.UnknownAs]
+	if level != 3 {
+		l.setLevel(level)
+	}
+	if l.holding {
+		l.held = append(l.held, output{absent: true, level: level, short: short})
+		return
+	}
+
+	l.addMsg(level, short, "")
+}
+
+// Add check message rendered from Load.Template with level of check
+func (l *Load) templateMsg() error {
+	t, err := template.New("msg").Parse(l.Template)
+	if err != nil {
+		return fmt.Errorf("template error: %v", err)
+	}
+
+	l.data.Type = l.Ctype
+	l.data.Load = l.norm
+
+	var b strings.Builder
+	err = t.Execute(&b, l.data)
+	if err != nil {
+		return fmt.Errorf("template error: %v", err)
+	}
+
+	short := b.String()
+	if l.Prefix != "" {
+		short = l.Prefix + ": " + short
+	}
+	if l.Buffered {
+		l.buffer = append(l.buffer, output{level: l.Check.RetVal(), short: short})
+		return nil
+	}
+	l.Check.AddMsg(l.Check.RetVal(), short, "")
+
+	return nil
+}
+
+// Report min, max and average cpu utilization of components. Alarm level is
+// calculated from max. Only unknown and missing data messages of check type and
+// check level output (expected components, imbalance) are kept.
+// Held output is reported as is if check type has no components.
+func (l *Load) aggregate() error {
+	held := l.held
+	l.held = nil
+
+	if len(l.data.Components) == 0 {
+		for _, o := range held {
+			if o.perf {
+				l.addPerf(o.label, o.value, o.unit, o.warn, o.crit, o.min, o.max)
+			} else {
+				l.setLevel(o.level)
+				l.addMsg(o.level, o.short, o.long)
+			}
+		}
+		return nil
+	}
+
+	var min, max, sum int64
+	first := true
+	for _, v := range l.data.Components {
+		if first || v < min {
+			min = v
+		}
+		if first || v > max {
+			max = v
+		}
+		sum += v
+		first = false
+	}
+	avg := roundVal(float64(sum)/float64(len(l.data.Components)), l.RoundMode)
+
+	level, err := l.alarmLevel("cpu_max", max, l.Warn, l.Crit)
+	if err != nil {
+		return fmt.Errorf("alarm level error: %v", err)
+	}
+
+	l.addMsg(level, fmt.Sprintf("%d components, cpu min %d%%, max %d%%, avg %d%%",
+		len(l.data.Components), min, max, avg), "")
+	for _, o := range held {
+		if o.perf && o.keep {
+			l.addPerf(o.label, o.value, o.unit, o.warn, o.crit, o.min, o.max)
+		} else if !o.perf && (o.level == 3 || o.absent || o.keep) {
+			l.addMsg(o.level, o.short, o.long)
+		}
+	}
+	l.addPerf("cpu_min", fmt.Sprintf("%d", min), "%", "", "", "0", "100")
+	l.addPerf("cpu_max", fmt.Sprintf("%d", max), "%", l.Warn, l.Crit, "0", "100")
+	l.addPerf("cpu_avg", fmt.Sprintf("%d", avg), "%", "", "", "0", "100")
+
+	return nil
+}
+
+// Parse Load.CompThresholds spec "<name or index>=<warn>/<crit>,..."
+func (l *Load) parseCompThresholds() error {
+	l.compLevels = make(levelMap)
+	if l.CompThresholds == "" {
+		return nil
+	}
+
+	for _, e := range strings.Split(l.CompThresholds, ",") {
+		kv := strings.SplitN(e, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("not valid component thresholds - %s", e)
+		}
+		wc := strings.Split(kv[1], "/")
+		if len(wc) != 2 || kv[0] == "" || wc[0] == "" || wc[1] == "" {
+			return fmt.Errorf("not valid component thresholds - %s", e)
+		}
+		l.compLevels[kv[0]] = [2]string{wc[0], wc[1]}
+	}
+
+	return nil
+}
+
+// Returns true if component name matches Load.AlarmRegex or it is not set.
+// Regex is compiled by Get.
+func (l *Load) alarmed(name string) bool {
+	if l.AlarmRegex == "" || l.alarmRe == nil {
+		return true
+	}
+	return l.alarmRe.MatchString(name)
+}
+
+// Returns warning and critical levels of component by name or index.
+// Global levels are returned for components without override.
+func (l *Load) componentLevels(name, idx string) (string, string) {
+	for _, k := range []string{name, idx} {
+		if v, ok := l.compLevels[k]; ok && k != "" {
+			return v[0], v[1]
+		}
+	}
+
+	return l.Warn, l.Crit
+}
+
+// Record cpu utilization of named component
+func (l *Load) component(n string, v int64) {
+	if l.data.Components == nil {
+		l.data.Components = make(map[string]int64)
+	}
+	l.data.Components[n] = v
+	l.normalized(v)
+}
+
+// Get Meraki load data from first available source. hrProcessorLoad average is
+// preferred, UCD-SNMP-MIB systemStats is used as fallback.
+func (l *Load) merakiLoad() error {
+	res, err := l.walk(hrProcessorLoad, true, true)
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(res))
+	}
+	if err == nil && len(res) > 0 {
+		return l.hostLoadData(res)
+	}
+
+	_, err = l.get([]string{ssCpuRawIdle})
+	if err == nil {
+		return l.cpuLoad()
+	}
+
+	return l.noSource()
+}
+
+// Get Fortigate load data using fgSysCpuUsage oid. With Load.Ha cluster members
+// are reported from fgHaStatsTable and the busiest member drives alarm level.
+func (l *Load) fortiLoad() error {
+	// Do SNMP query
+	res, err := l.get([]string{fgSysCpuUsage})
+	if err != nil {
+		return fmt.Errorf("snmp error: %v", err)
+	}
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(res))
+	}
+
+	u, err := numValue(res, fgSysCpuUsage)
+	if err != nil {
+		return fmt.Errorf("cpu data error: %v", err)
+	}
+
+	members := make(map[string]int64)
+	if l.Ha {
+		members, err = l.fortiHaMembers()
+		if err != nil {
+			return err
+		}
+	}
+
+	// Standalone unit
+	if len(members) == 0 {
+		level, err := l.alarmLevel("cpu_usage", u, l.Warn, l.Crit)
+		if err != nil {
+			return fmt.Errorf("alarm level error: %v", err)
+		}
+
+		l.addPerf("cpu_usage", fmt.Sprintf("%d", u), "%", l.Warn, l.Crit, "0", "100")
+		l.addMsg(level, fmt.Sprintf("usage %d%%", u), "")
+		l.normalized(u)
+
+		return nil
+	}
+
+	l.addPerf("cpu_usage", fmt.Sprintf("%d", u), "%", "", "", "0", "100")
+
+	mn := make([]string, 0, len(members))
+	for k := range members {
+		mn = append(mn, k)
+	}
+	sort.Strings(mn)
+
+	var utils []int64
+	for _, n := range mn {
+		v := members[n]
+		level, err := l.alarmLevel(n+" cpu usage", v, l.Warn, l.Crit)
+		if err != nil {
+			return fmt.Errorf("alarm level error: %v", err)
+		}
+
+		l.addPerf("'"+n+" cpu usage'", fmt.Sprintf("%d", v), "%", l.Warn, l.Crit, "0", "100")
+		l.addMsg(level, fmt.Sprintf("%s usage %d%%", n, v), "")
+		l.component(n, v)
+		utils = append(utils, v)
+	}
+	l.imbalance(utils)
+
+	return nil
+}
+
+// Returns cpu usage of Fortigate HA cluster members by serial number.
+// Returns empty map for standalone unit.
+func (l *Load) fortiHaMembers() (map[string]int64, error) {
+	members := make(map[string]int64)
+
+	res, err := l.get([]string{fgHaSystemMode})
+	if err != nil {
+		return nil, fmt.Errorf("snmp error: %v", err)
+	}
+	// standalone(1)
+	if res[fgHaSystemMode].Integer == 1 {
+		return members, nil
+	}
+
+	cpu, err := l.walk(fgHaStatsCpuUsage, true, true)
+	if err != nil {
+		return nil, fmt.Errorf("snmp error: %v", err)
+	}
+
+	serial, err := l.walk(fgHaStatsSerial, true, true)
+	if err != nil {
+		return nil, fmt.Errorf("snmp error: %v", err)
+	}
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(cpu))
+		fmt.Printf("%# v\n", pretty.Formatter(serial))
+	}
+
+	for i := range cpu {
+		v, err := numValue(cpu, i)
+		if err != nil {
+			return nil, fmt.Errorf("cpu data error: %v", err)
+		}
+
+		n := "member" + i
+		if s := serial[i].OctetString; s != "" {
+			n = s
+		}
+		members[n] = v
+	}
+
+	return members, nil
+}
+
+// Get Supermicro server load data. Average hrProcessorLoad drives alarm level,
+// per core loads and laTable load averages are reported when available.
+func (l *Load) supermicroLoad() error {
+	res, err := l.walk(hrProcessorLoad, true, true)
+	if err != nil {
+		return fmt.Errorf("snmp error: %v", err)
+	}
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(res))
+	}
+
+	err = l.hostLoadData(res)
+	if err != nil {
+		return err
+	}
+	l.coreLoads(res)
+	l.loadAverages()
+
+	return nil
+}
+
+// Get VoIP gateway, SBC and other appliance load data using hrProcessorLoad average
+func (l *Load) endpointLoad() error {
+	res, err := l.walk(hrProcessorLoad, true, true)
+	if err != nil {
+		if emptyWalk(err) {
+			l.addAbsent("no processors found in hrProcessorLoad")
+			return nil
+		}
+		return fmt.Errorf("snmp error: %v", err)
+	}
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(res))
+	}
+
+	return l.hostLoadData(res)
+}
+
+// Get load data from first candidate oid of Load.ProbeOrder returning valid 0-100
+// cpu utilization. hrProcessorLoad candidate is reported as host check.
+func (l *Load) firstofLoad() error {
+	oids, err := l.probeOids()
+	if err != nil {
+		return err
+	}
+
+	for _, o := range oids {
+		if o == hrProcessorLoad {
+			res, err := l.walk(hrProcessorLoad, true, true)
+			if err == nil && len(res) > 0 {
+				return l.hostLoadData(res)
+			}
+			// DEBUG
+			if l.Debug {
+				fmt.Printf("candidate %s failed: %v\n", o, err)
+			}
+			continue
+		}
+
+		res, err := l.get([]string{o})
+		if err != nil {
+			// DEBUG
+			if l.Debug {
+				fmt.Printf("candidate %s failed: %v\n", o, err)
+			}
+			continue
+		}
+		// DEBUG
+		if l.Debug {
+			fmt.Printf("%# v\n", pretty.Formatter(res))
+		}
+
+		u, err := numValue(res, o)
+		if err != nil || u < 0 || u > 100 {
+			// DEBUG
+			if l.Debug {
+				fmt.Printf("candidate %s has no valid value: %d %v\n", o, u, err)
+			}
+			continue
+		}
+
+		level, err := l.alarmLevel("cpu_usage", u, l.Warn, l.Crit)
+		if err != nil {
+			return fmt.Errorf("alarm level error: %v", err)
+		}
+		l.addPerf("cpu_usage", fmt.Sprintf("%d", u), "%", l.Warn, l.Crit, "0", "100")
+		l.addMsg(level, fmt.Sprintf("usage %d%% from %s", u, o), "")
+		l.normalized(u)
+
+		return nil
+	}
+
+	l.addAbsent(fmt.Sprintf("cpu data not available from any of %d candidate oids", len(oids)))
+
+	return nil
+}
+
+// Get TP-Link JetStream and Omada load data using tpSysMonitorCpu1Minute or
+// hrProcessorLoad average, whichever is available. Stacked units of vendor table
+// are alarmed separately.
+func (l *Load) tplinkLoad() error {
+	res, err := l.walk(tpSysMonitorCpu1Minute, true, true)
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(res))
+	}
+	if err == nil && len(res) > 0 {
+		units := make([]int, 0, len(res))
+		for k := range res {
+			if i, err := strconv.Atoi(k); err == nil {
+				units = append(units, i)
+			}
+		}
+		sort.Ints(units)
+
+		for _, i := range units {
+			u, err := numValue(res, strconv.Itoa(i))
+			if err != nil {
+				l.addAbsent(fmt.Sprintf("unit%d usage Na", i))
+				continue
+			}
+
+			level, err := l.alarmLevel(fmt.Sprintf("unit%d usage", i), u, l.Warn, l.Crit)
+			if err != nil {
+				return fmt.Errorf("alarm level error: %v", err)
+			}
+
+			// Single unit switches are reported without unit number
+			if len(units) == 1 {
+				l.addPerf("cpu_usage", fmt.Sprintf("%d", u), "%", l.Warn, l.Crit, "0", "100")
+				l.addMsg(level, fmt.Sprintf("usage %d%%", u), "")
+				l.normalized(u)
+				continue
+			}
+			n := fmt.Sprintf("unit%d", i)
+			l.addPerf("'"+n+" usage'", fmt.Sprintf("%d", u), "%", l.Warn, l.Crit, "0", "100")
+			l.addMsg(level, fmt.Sprintf("%s usage %d%%", n, u), "")
+			l.component(n, u)
+		}
+		return nil
+	} else if err != nil && !emptyWalk(err) {
+		return fmt.Errorf("snmp error: %v", err)
+	}
+
+	res, err = l.walk(hrProcessorLoad, true, true)
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(res))
+	}
+	if err == nil && len(res) > 0 {
+		err = l.hostLoadData(res)
+		if err != nil {
+			return err
+		}
+		if len(res) > 1 {
+			l.coreLoads(res)
+		}
+		return nil
+	} else if err != nil && !emptyWalk(err) {
+		return fmt.Errorf("snmp error: %v", err)
+	}
+
+	res, err = l.get([]string{sysObjectID})
+	if err != nil {
+		return fmt.Errorf("snmp error: %v", err)
+	}
+	l.addAbsent("cpu data not available, sysObjectID " + res[sysObjectID].ObjectIdentifier)
+
+	return nil
+}
+
+// Get Aruba Instant cluster load data from virtual controller using aiAccessPointTable.
+// Only busiest AP is alarmed. Single AP deployments are reported without AP name.
+func (l *Load) arubaInstantLoad() error {
+	res, err := l.walk(aiAPCPUUtilization, true, true)
+	if err != nil {
+		return fmt.Errorf("snmp error: %v", err)
+	}
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(res))
+	}
+
+	// Names are optional. Failed query leaves AP MAC based index as name.
+	names, err := l.walk(aiAPName, true, true)
+	if err != nil && l.Debug {
+		fmt.Printf("name query failed: %v\n", err)
+	}
+
+	aps := make(map[string]string)
+	for i := range res {
+		n := names[i].OctetString
+		if n == "" {
+			n = "ap " + i
+		}
+		aps[n] = i
+	}
+	an := make([]string, 0, len(aps))
+	for n := range aps {
+		an = append(an, n)
+	}
+	sort.Strings(an)
+
+	busiest, v := "", int64(0)
+	for _, n := range an {
+		u, err := numValue(res, aps[n])
+		if err != nil {
+			l.addAbsent(n + " cpu Na")
+			continue
+		}
+		if busiest == "" || u > v {
+			busiest, v = n, u
+		}
+		if len(an) == 1 {
+			l.addPerf("cpu_usage", fmt.Sprintf("%d", u), "%", l.Warn, l.Crit, "0", "100")
+			l.normalized(u)
+			continue
+		}
+		l.addPerf("'"+n+" cpu'", fmt.Sprintf("%d", u), "%", l.Warn, l.Crit, "0", "100")
+		l.component(n, u)
+	}
+	if busiest == "" {
+		return nil
+	}
+
+	level, err := l.alarmLevel("ap busiest", v, l.Warn, l.Crit)
+	if err != nil {
+		return fmt.Errorf("alarm level error: %v", err)
+	}
+	if len(an) == 1 {
+		l.addMsg(level, fmt.Sprintf("usage %d%%", v), "")
+	} else {
+		l.addMsg(level, fmt.Sprintf("%s cpu %d%% (busiest of %d APs)", busiest, v, len(an)), "")
+	}
+
+	return nil
+}
+
+// Get OPNsense firewall load data using hrProcessorLoad average. Per core loads
+// and pf state table usage of BEGEMOT-PF-MIB are reported if available.
+func (l *Load) opnsenseLoad() error {
+	res, err := l.walk(hrProcessorLoad, true, true)
+	if err != nil {
+		if emptyWalk(err) {
+			l.addAbsent("no processors found in hrProcessorLoad")
+			return nil
+		}
+		return fmt.Errorf("snmp error: %v", err)
+	}
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(res))
+	}
+
+	err = l.hostLoadData(res)
+	if err != nil {
+		return err
+	}
+	l.coreLoads(res)
+
+	// Pf state table usage is optional. Failed query leaves it out.
+	res, err = l.get([]string{pfStateTableCount, pfLimitsStates})
+	if err != nil {
+		// DEBUG
+		if l.Debug {
+			fmt.Printf("pf state query failed: %v\n", err)
+		}
+		return nil
+	}
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(res))
+	}
+
+	cnt, err := numValue(res, pfStateTableCount)
+	if err != nil {
+		return nil
+	}
+	limit, err := numValue(res, pfLimitsStates)
+	if err != nil || limit <= 0 {
+		l.addPerf("pf_states", fmt.Sprintf("%d", cnt), "", "", "", "0", "")
+		l.addMsg(0, fmt.Sprintf("pf states %d", cnt), "")
+		return nil
+	}
+
+	l.addPerf("pf_states", fmt.Sprintf("%d", cnt), "", "", "", "0", fmt.Sprintf("%d", limit))
+	l.addMsg(0, fmt.Sprintf("pf states %d of %d (%d%%)", cnt, limit, cnt*100/limit), "")
+
+	return nil
+}
+
+// Get Infoblox NIOS load data using ibSystemMonitorCpuUsage. Grid master and
+// members are checked alike. Per core loads are reported if hrProcessorLoad is available.
+func (l *Load) infobloxLoad() error {
+	res, err := l.get([]string{ibSystemMonitorCpuUsage})
+	if err != nil {
+		return fmt.Errorf("snmp error: %v", err)
+	}
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(res))
+	}
+
+	u, err := numValue(res, ibSystemMonitorCpuUsage)
+	if err != nil {
+		l.addAbsent("ibSystemMonitorCpuUsage not available")
+		return nil
+	}
+
+	level, err := l.alarmLevel("cpu_usage", u, l.Warn, l.Crit)
+	if err != nil {
+		return fmt.Errorf("alarm level error: %v", err)
+	}
+
+	l.addPerf("cpu_usage", fmt.Sprintf("%d", u), "%", l.Warn, l.Crit, "0", "100")
+	l.addMsg(level, fmt.Sprintf("usage %d%%", u), "")
+	l.normalized(u)
+
+	// Per core loads are optional. Failed query leaves them out.
+	res, err = l.walk(hrProcessorLoad, true, true)
+	if err != nil {
+		// DEBUG
+		if l.Debug {
+			fmt.Printf("core load query failed: %v\n", err)
+		}
+		return nil
+	}
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(res))
+	}
+	if len(res) > 1 {
+		l.coreLoads(res)
+	}
+
+	return nil
+}
 
-// .iso.org.dod.internet.private.enterprises.ucdavis.systemStats.ssCpuUser
-const ssCpuUser = ".1.3.6.1.4.1.2021.11.9.0"
+// Get Netgear managed switch load data using agentSwitchCpuProcessTotalUtilization
+// or hrProcessorLoad average, whichever is available. Alarm level is calculated
+// from 60 sec utilization, 5 and 300 sec utilizations are reported as perfdata only.
+// Stacked M-series units are reported and alarmed separately by unit number index.
+func (l *Load) netgearLoad() error {
+	for _, o := range []string{agentSwitchCpuProcessTotalUtilization, fpSwitchCpuProcessTotalUtilization} {
+		res, err := l.walk(o, true, true)
+		if err != nil || len(res) == 0 {
+			// DEBUG
+			if l.Debug {
+				fmt.Printf("%s failed: %v\n", o, err)
+			}
+			continue
+		}
+		// DEBUG
+		if l.Debug {
+			fmt.Printf("%# v\n", pretty.Formatter(res))
+		}
 
-// .iso.org.dod.internet.private.enterprises.ucdavis.systemStats.ssCpuSystem
-const ssCpuSystem = ".1.3.6.1.4.1.2021.11.10.0"
+		units := make([]int, 0, len(res))
+		for k := range res {
+			if i, err := strconv.Atoi(k); err == nil {
+				units = append(units, i)
+			}
+		}
+		sort.Ints(units)
 
-// .iso.org.dod.internet.private.enterprises.ucdavis.systemStats.ssCpuIdle
-const ssCpuRawIdle = ".1.3.6.1.4.1.2021.11.11.0"
+		found := false
+		for _, i := range units {
+			k := strconv.Itoa(i)
+			utils := make(map[string]float64)
+			for _, m := range netgearUtil.FindAllStringSubmatch(res[k].OctetString, -1) {
+				if v, err := strconv.ParseFloat(m[2], 64); err == nil {
+					utils[m[1]] = v
+				}
+			}
+			util, ok := utils["60"]
+			if !ok {
+				// DEBUG
+				if l.Debug {
+					fmt.Printf("%s.%s has no 60 sec utilization: %q\n", o, k, res[k].OctetString)
+				}
+				continue
+			}
+			found = true
+			u := roundVal(util, l.RoundMode)
 
-// .iso.org.dod.internet.private.enterprises.ucdavis.laTable.laEntry.laLoadInt
-const laLoadInt = ".1.3.6.1.4.1.2021.10.1.5"
+			// Standalone switches are reported without unit number
+			n, label := "", func(p string) string { return "usage_" + p + "s" }
+			if len(units) > 1 {
+				n = "unit" + k
+				label = func(p string) string { return "'" + n + " " + p + "s'" }
+			}
 
-// .iso.org.dod.internet.private.enterprises.juniperMIB.jnxMibs.jnxBoxAnatomy.jnxOperatingTable.jnxOperatingEntry.jnxOperatingDescr
-const jnxOperatingDescr = ".1.3.6.1.4.1.2636.3.1.13.1.5"
+			level, err := l.alarmLevel(label("60"), u, l.Warn, l.Crit)
+			if err != nil {
+				return fmt.Errorf("alarm level error: %v", err)
+			}
 
-// .iso.org.dod.internet.private.enterprises.juniperMIB.jnxMibs.jnxBoxAnatomy.jnxOperatingTable.jnxOperatingEntry.jnxOperatingCPU
-const jnxOperatingCPU = ".1.3.6.1.4.1.2636.3.1.13.1.8"
+			l.addPerf(label("60"), l.pct(util, u), "%", l.Warn, l.Crit, "0", "100")
+			for _, p := range []string{"5", "300"} {
+				if v, ok := utils[p]; ok {
+					l.addPerf(label(p), l.pct(v, roundVal(v, l.RoundMode)), "%", "", "", "0", "100")
+				}
+			}
+			if n == "" {
+				l.addMsg(level, fmt.Sprintf("usage 60s %d%%", u), "")
+				l.normalized(u)
+				continue
+			}
+			l.addMsg(level, fmt.Sprintf("%s usage 60s %d%%", n, u), "")
+			l.component(n, u)
+		}
+		if found {
+			return nil
+		}
+	}
 
-// .iso.org.dod.internet.private.enterprises.juniperMIB.jnxMibs.jnxBoxAnatomy.jnxOperatingTable.jnxOperatingEntry.jnxOperating1MinLoadAvg
-const jnxOperating1MinLoadAvg = ".1.3.6.1.4.1.2636.3.1.13.1.20"
+	res, err := l.walk(hrProcessorLoad, true, true)
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(res))
+	}
+	if err == nil && len(res) > 0 {
+		err = l.hostLoadData(res)
+		if err != nil {
+			return err
+		}
+		if len(res) > 1 {
+			l.coreLoads(res)
+		}
+		return nil
+	} else if err != nil && !emptyWalk(err) {
+		return fmt.Errorf("snmp error: %v", err)
+	}
 
-// .iso.org.dod.internet.private.enterprises.juniperMIB.jnxMibs.jnxBoxAnatomy.jnxOperatingTable.jnxOperatingEntry.jnxOperating5MinLoadAvg
-const jnxOperating5MinLoadAvg = ".1.3.6.1.4.1.2636.3.1.13.1.21"
+	res, err = l.get([]string{sysObjectID})
+	if err != nil {
+		return fmt.Errorf("snmp error: %v", err)
+	}
+	l.addAbsent("cpu data not available, sysObjectID " + res[sysObjectID].ObjectIdentifier)
 
-// .iso.org.dod.internet.private.enterprises.cisco.ciscoMgmt.ciscoProcessMIB.ciscoProcessMIBObjects.cpmCPU.cpmCPUTotalTable.cpmCPUTotalEntry.cpmCPUTotalPhysicalIndex
-const cpmCPUTotalPhysicalIndex = ".1.3.6.1.4.1.9.9.109.1.1.1.1.2"
+	return nil
+}
 
-// .iso.org.dod.internet.private.enterprises.cisco.ciscoMgmt.ciscoProcessMIB.ciscoProcessMIBObjects.cpmCPU.cpmCPUTotalTable.cpmCPUTotalEntry.cpmCPUTotal1minRev
-const cpmCPUTotal1minRev = ".1.3.6.1.4.1.9.9.109.1.1.1.1.7"
+// Get Linux router (VyOS, EdgeOS) load data. Alarm level is calculated from ssCpuIdle
+// or hrProcessorLoad average if systemStats is not available. Per core loads and
+// laTable load averages scaled by core count are reported if available.
+func (l *Load) routerLinuxLoad() error {
+	cores, herr := l.walk(hrProcessorLoad, true, true)
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(cores))
+	}
+	if herr != nil {
+		cores = nil
+	}
 
-// .iso.org.dod.internet.private.enterprises.cisco.ciscoMgmt.ciscoProcessMIB.ciscoProcessMIBObjects.cpmCPU.cpmCPUTotalTable.cpmCPUTotalEntry.cpmCPUTotal5minRev
-const cpmCPUTotal5minRev = ".1.3.6.1.4.1.9.9.109.1.1.1.1.8"
+	var err error
+	if _, ierr := l.get([]string{ssCpuRawIdle}); ierr == nil {
+		err = l.cpuLoad()
+	} else if len(cores) > 0 {
+		err = l.hostLoadData(cores)
+	} else {
+		return l.noSource()
+	}
+	if err != nil {
+		return err
+	}
 
-// .iso.org.dod.internet.private.enterprises.timetra.timetraProducts.tmnxSRMIB.tmnxSRObjs.tmnxSysObjs.sysGenInfo.tmnxSysCpuMonTable.tmnxSysCpuMonEntry.tmnxSysCpuMonCpuIdle
-const tmnxSysCpuMonCpuIdle = ".1.3.6.1.4.1.6527.3.1.2.1.1.12.1.2"
+	if len(cores) > 0 {
+		l.coreLoads(cores)
+		l.scaledLoadAverages(len(cores))
+	}
 
-// .iso.org.dod.internet.mgmt.mib-2.entityMIB.entityMIBObjects.entityPhysical.entPhysicalTable.entPhysicalEntry.entPhysicalName
-const entPhysicalName = ".1.3.6.1.2.1.47.1.1.1.1.7"
+	return nil
+}
 
-// .iso.org.dod.internet.private.enterprises.ruggedcom.ruggedcomMgmt.rcSysInfo.rcDeviceStatus.rcDeviceStsCpuUsagePercent
-const rcDeviceStsCpuUsagePercent = ".1.3.6.1.4.1.15004.4.2.2.6.0"
+// Returns number of cpu cores used in loadavg levels. Cores are counted from
+// hrProcessorTable, hrDeviceTable processor entries, laTable rows or set by Load.CPUCountSource.
+func (l *Load) cpuCount() (int, error) {
+	src := l.CPUCountSource
+	if src == "" {
+		src = "hrprocessor"
+	}
 
-// Do the work
-func (l *Load) Get() error {
-	switch l.Ctype {
-	case "host":
-		err := l.hostLoad()
-		if err != nil {
-			return err
-		}
-	case "sysstats":
-		err := l.cpuLoad()
+	cnt := 0
+	switch src {
+	case "hrprocessor":
+		res, err := l.walk(hrProcessorLoad, true, true)
 		if err != nil {
-			return err
+			return 0, fmt.Errorf("snmp error: %v", err)
 		}
-	case "loadavg":
-		err := l.sysLoad()
-		if err != nil {
-			return err
+		// DEBUG
+		if l.Debug {
+			fmt.Printf("%# v\n", pretty.Formatter(res))
 		}
-	case "jnx":
-		err := l.jnxLoad()
+		cnt = len(res)
+	case "hrdevice":
+		res, err := l.walk(hrDeviceType, true, true)
 		if err != nil {
-			return err
+			return 0, fmt.Errorf("snmp error: %v", err)
 		}
-	case "cisco":
-		err := l.ciscoLoad()
-		if err != nil {
-			return err
+		// DEBUG
+		if l.Debug {
+			fmt.Printf("%# v\n", pretty.Formatter(res))
 		}
-	case "timetra":
-		err := l.timetraLoad()
-		if err != nil {
-			return err
+		for _, d := range res {
+			if d.ObjectIdentifier == hrDeviceProcessor {
+				cnt++
+			}
 		}
-	case "rcsw":
-		err := l.ruggedSwLoad()
+	case "laTable":
+		res, err := l.walk(laIndex, true, true)
 		if err != nil {
-			return err
+			return 0, fmt.Errorf("snmp error: %v", err)
 		}
-	case "moxasw":
-		err := l.moxaSwLoad()
-		if err != nil {
-			return err
+		// DEBUG
+		if l.Debug {
+			fmt.Printf("%# v\n", pretty.Formatter(res))
 		}
+		cnt = len(res)
 	default:
-		return fmt.Errorf("no such check type")
+		v, err := strconv.Atoi(src)
+		if err != nil || v < 1 {
+			return 0, fmt.Errorf("not valid cpu count source - %s", src)
+		}
+		cnt = v
+		src = "submitted value"
 	}
 
-	return nil
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("cpu count %d from %s\n", cnt, src)
+	}
+
+	if cnt == 0 {
+		return 0, fmt.Errorf("get processor count failed: no processors found in %s", src)
+	}
+
+	return cnt, nil
 }
 
-// Get load data using hrProcessorLoad oid
-func (l *Load) hostLoad() error {
-	// Do SNMP query
-	res, err := l.Sess.Walk(hrProcessorLoad, true, true)
+// Report laTable 1, 5 and 15 min load averages as % of cnt cores if available
+func (l *Load) scaledLoadAverages(cnt int) {
+	o := []string{laLoadInt + ".1", laLoadInt + ".2", laLoadInt + ".3"}
+	res, err := l.get(o)
 	if err != nil {
-		return fmt.Errorf("snmp error: %v", err)
+		// DEBUG
+		if l.Debug {
+			fmt.Printf("load average query failed: %v\n", err)
+		}
+		return
 	}
 	// DEBUG
 	if l.Debug {
 		fmt.Printf("%# v\n", pretty.Formatter(res))
 	}
 
-	cpuData, err := calcCPUData(res)
+	for i, p := range []string{"1", "5", "15"} {
+		v := roundVal(float64(res[o[i]].Integer)/float64(cnt), l.RoundMode)
+		l.addPerf("load_"+p+"_min", fmt.Sprintf("%d", v), "%", "", "", "0", "")
+		l.addMsg(0, fmt.Sprintf("l%s %d%%", p, v), "")
+	}
+}
+
+// Get Brocade FC switch load data using swCpuUsage oid. Usage limit and polling
+// interval of switch cpu monitor are reported as context if available.
+func (l *Load) brocadeFcLoad() error {
+	// Do SNMP query
+	res, err := l.get([]string{swCpuUsage})
 	if err != nil {
-		return fmt.Errorf("cpu data error: %v", err)
+		// Access Gateway mode may lack cpu object of reachable switch
+		if _, rerr := l.get([]string{sysUpTime}); rerr == nil {
+			l.addAbsent("swCpuUsage not available")
+			return nil
+		}
+		return fmt.Errorf("snmp error: %v", err)
 	}
 	// DEBUG
 	if l.Debug {
-		fmt.Printf("%# v\n", pretty.Formatter(cpuData))
+		fmt.Printf("%# v\n", pretty.Formatter(res))
 	}
 
-	level, err := l.Check.AlarmLevel(int64(cpuData["load"]), l.Warn, l.Crit)
+	u := res[swCpuUsage].Integer
+
+	level, err := l.alarmLevel("cpu_usage", u, l.Warn, l.Crit)
 	if err != nil {
 		return fmt.Errorf("alarm level error: %v", err)
 	}
 
-	l.Check.AddPerfData("'cpu usage'", fmt.Sprintf("%d", cpuData["load"]), "%", l.Warn, l.Crit, "0", "100")
-	l.Check.AddPerfData("'cpu count'", fmt.Sprintf("%d", cpuData["cpuCnt"]), "", "", "", "", "")
-	l.Check.AddPerfData("dummy", "0", "", "", "", "", "")
-	l.Check.AddMsg(level, fmt.Sprintf("%d CPUs; load %d%%", cpuData["cpuCnt"], cpuData["load"]), "")
+	l.addPerf("cpu_usage", fmt.Sprintf("%d", u), "%", l.Warn, l.Crit, "0", "100")
+	l.addMsg(level, fmt.Sprintf("usage %d%%", u), "")
+	l.normalized(u)
+
+	// Monitor settings are optional. Failed query leaves them out.
+	res, err = l.get([]string{swCpuUsageLimit, swCpuPollingInterval})
+	if err != nil {
+		// DEBUG
+		if l.Debug {
+			fmt.Printf("cpu monitor query failed: %v\n", err)
+		}
+		return nil
+	}
+	l.addMsg(0, fmt.Sprintf("limit %d%%, polling interval %ds", res[swCpuUsageLimit].Integer, res[swCpuPollingInterval].Integer), "")
 
 	return nil
 }
 
-// Get load data using ssCpuIdle oid
-func (l *Load) cpuLoad() error {
-	// Do SNMP query
-	res, err := l.Sess.Get([]string{ssCpuUser, ssCpuSystem, ssCpuRawIdle})
+// Get MikroTik load data using hrProcessorLoad. Single core instances (CHR) are
+// reported with unscaled load averages if available, multi core devices (CCR)
+// with per core loads.
+func (l *Load) mikrotikLoad() error {
+	res, err := l.walk(hrProcessorLoad, true, true)
 	if err != nil {
+		if emptyWalk(err) {
+			l.addAbsent("no processors found in hrProcessorLoad")
+			return nil
+		}
 		return fmt.Errorf("snmp error: %v", err)
 	}
 	// DEBUG
@@ -165,31 +3701,28 @@ func (l *Load) cpuLoad() error {
 		fmt.Printf("%# v\n", pretty.Formatter(res))
 	}
 
-	d := map[string]int64{
-		"used": 100 - int64(res[ssCpuRawIdle].Integer),
-		"user": int64(res[ssCpuUser].Integer),
-		"sys":  int64(res[ssCpuSystem].Integer),
-	}
-
-	level, err := l.Check.AlarmLevel(d["used"], l.Warn, l.Crit)
+	err = l.hostLoadData(res)
 	if err != nil {
-		return fmt.Errorf("alarm level error: %v", err)
+		return err
 	}
 
-	l.Check.AddPerfData("cpu_prct_used", fmt.Sprintf("%d", d["used"]), "%", l.Warn, l.Crit, "0", "100")
-	l.Check.AddPerfData("cpu_prct_user", fmt.Sprintf("%d", d["user"]), "%", "", "", "0", "100")
-	l.Check.AddPerfData("cpu_prct_system", fmt.Sprintf("%d", d["sys"]), "%", "", "", "0", "100")
-	l.Check.AddMsg(level, fmt.Sprintf("load %d%%", d["used"]), "")
-	l.Check.AddMsg(level, fmt.Sprintf("user %d%%", d["user"]), "")
-	l.Check.AddMsg(level, fmt.Sprintf("system %d%%", d["sys"]), "")
+	if len(res) == 1 {
+		l.loadAverages()
+		return nil
+	}
+	l.coreLoads(res)
 
 	return nil
 }
 
-// Get load data using laLoadInt oid
-func (l *Load) sysLoad() error {
-	// Get processor count
-	res, err := l.Sess.Walk(hrProcessorLoad, true, true)
+// Get management appliance and PDU controller load data using vendor cpu utilization
+// oid if set or hrProcessorLoad average
+func (l *Load) applianceLoad() error {
+	if l.ApplianceOid == "" {
+		return l.endpointLoad()
+	}
+
+	res, err := l.get([]string{l.ApplianceOid})
 	if err != nil {
 		return fmt.Errorf("snmp error: %v", err)
 	}
@@ -198,162 +3731,180 @@ func (l *Load) sysLoad() error {
 		fmt.Printf("%# v\n", pretty.Formatter(res))
 	}
 
-	pCnt := len(res)
-	if pCnt == 0 {
-		return fmt.Errorf("get processor count failed: %v", err)
+	u, err := numValue(res, l.ApplianceOid)
+	if err != nil {
+		l.addAbsent("cpu data not available, " + err.Error())
+		return nil
 	}
 
-	wPerc, err := strconv.Atoi(l.Warn)
-	if err != nil {
-		return fmt.Errorf("warning level must be integer: %v", err)
+	// Percentage values are clamped to 0-100. Other units are reported as is.
+	unit, min, max := "%", "0", "100"
+	if l.UnitSet {
+		unit, min, max = l.Unit, "", ""
+	}
+	if unit == "%" {
+		min, max = "0", "100"
+		if u < 0 {
+			u = 0
+		}
+		if u > 100 {
+			u = 100
+		}
 	}
 
-	cPerc, err := strconv.Atoi(l.Crit)
+	level, err := l.alarmLevel("cpu_usage", u, l.Warn, l.Crit)
 	if err != nil {
-		return fmt.Errorf("critical level must be integer: %v", err)
+		return fmt.Errorf("alarm level error: %v", err)
 	}
 
-	w1 := pCnt * wPerc
-	c1 := pCnt * cPerc
-	w5 := pCnt * (wPerc - 5)
-	c5 := pCnt * (cPerc - 5)
-	w15 := pCnt * (wPerc - 10)
-	c15 := pCnt * (cPerc - 10)
+	l.addPerf("cpu_usage", fmt.Sprintf("%d", u), unit, l.Warn, l.Crit, min, max)
+	l.addMsg(level, fmt.Sprintf("usage %d%s", u, unit), "")
+	if unit == "%" {
+		l.normalized(u)
+	}
 
-	loads := map[string]map[string]string{
-		"l1": {
-			"oid":   laLoadInt + ".1",
-			"name":  "load_1_min",
-			"warn":  strconv.Itoa(w1),
-			"crit":  strconv.Itoa(c1),
-			"wReal": fmt.Sprintf("%.2f", float64(w1)/100),
-			"cReal": fmt.Sprintf("%.2f", float64(c1)/100),
-		},
-		"l5": {
-			"oid":   laLoadInt + ".2",
-			"name":  "load_5_min",
-			"warn":  strconv.Itoa(w5),
-			"crit":  strconv.Itoa(c5),
-			"wReal": fmt.Sprintf("%.2f", float64(w5)/100),
-			"cReal": fmt.Sprintf("%.2f", float64(c5)/100),
-		},
-		"l15": {
-			"oid":   laLoadInt + ".3",
-			"name":  "load_15_min",
-			"warn":  strconv.Itoa(w15),
-			"crit":  strconv.Itoa(c15),
-			"wReal": fmt.Sprintf("%.2f", float64(w15)/100),
-			"cReal": fmt.Sprintf("%.2f", float64(c15)/100),
-		},
+	return nil
+}
+
+// Report per core loads of hrProcessorLoad walk result as informational data.
+// Cores are numbered in order of hrDeviceIndex.
+func (l *Load) coreLoads(res snmphelper.SnmpOut) {
+	idx := make([]int, 0, len(res))
+	for k := range res {
+		i, err := strconv.Atoi(k)
+		if err != nil {
+			continue
+		}
+		idx = append(idx, i)
 	}
+	sort.Ints(idx)
 
-	// Do SNMP query
-	res, err = l.Sess.Get([]string{loads["l1"]["oid"], loads["l5"]["oid"], loads["l15"]["oid"]})
+	for n, i := range idx {
+		v := res[strconv.Itoa(i)].Integer
+		l.addPerf(fmt.Sprintf("'core%d usage'", n), fmt.Sprintf("%d", v), "%", "", "", "0", "100")
+	}
+}
+
+// Report laTable 1, 5 and 15 min load averages as informational data if available
+func (l *Load) loadAverages() {
+	o := []string{laLoadInt + ".1", laLoadInt + ".2", laLoadInt + ".3"}
+	res, err := l.get(o)
 	if err != nil {
-		return fmt.Errorf("snmp error: %v", err)
+		// DEBUG
+		if l.Debug {
+			fmt.Printf("load average query failed: %v\n", err)
+		}
+		return
 	}
 	// DEBUG
 	if l.Debug {
 		fmt.Printf("%# v\n", pretty.Formatter(res))
 	}
 
-	l.Check.AddMsg(0, fmt.Sprintf("%d CPUs", pCnt), "")
-
-	for _, p := range [3]string{"l1", "l5", "l15"} {
-		v := res[loads[p]["oid"]].Integer
-		level, err := l.Check.AlarmLevel(v, loads[p]["warn"], loads[p]["crit"])
-		if err != nil {
-			return fmt.Errorf("alarm level error: %v", err)
-		}
-
-		vReal := fmt.Sprintf("%.2f", float64(v)/100)
-		l.Check.AddPerfData(loads[p]["name"], vReal, "", loads[p]["wReal"], loads[p]["cReal"], "0", "")
-		l.Check.AddMsg(level, fmt.Sprintf("%s %s", p, vReal), "")
+	for i, p := range []string{"1", "5", "15"} {
+		vReal := fmt.Sprintf("%.2f", float64(res[o[i]].Integer)/100)
+		l.addPerf("load_"+p+"_min", vReal, "", "", "", "0", "")
+		l.addMsg(0, fmt.Sprintf("l%s %s", p, vReal), "")
 	}
-
-	return nil
 }
 
-// Get Juniper load data using jnxOperatingTable
-func (l *Load) jnxLoad() error {
-	// Find routing engines
-	res, err := l.Sess.Walk(jnxOperatingDescr, true, true)
+// Check snmp reachability using sysUpTime oid. No cpu data is queried.
+func (l *Load) reachable() error {
+	level := 2
+	switch l.Unreachable {
+	case "", "critical":
+	case "warning":
+		level = 1
+	default:
+		return fmt.Errorf("not valid unreachable level - %s", l.Unreachable)
+	}
+
+	// Do SNMP query
+	res, err := l.get([]string{sysUpTime})
 	if err != nil {
-		return fmt.Errorf("snmp error: %v", err)
+		l.setLevel(level)
+		l.addMsg(level, fmt.Sprintf("snmp unreachable: %v", err), "")
+		return nil
 	}
 	// DEBUG
 	if l.Debug {
 		fmt.Printf("%# v\n", pretty.Formatter(res))
 	}
 
-	re := make(map[string]string)
-	for i, d := range res {
-		if strings.Contains(strings.ToUpper(d.OctetString), strings.ToUpper("Routing Engine")) {
-			re[i] = d.OctetString
-		}
-	}
+	// sysUpTime is in hundredths of a second
+	up := res[sysUpTime].TimeTicks / 100
 
-	loads := make(map[string]map[string]uint64)
-	for i, n := range re {
-		// Do SNMP query
-		o := []string{jnxOperatingCPU + "." + i, jnxOperating1MinLoadAvg + "." + i, jnxOperating5MinLoadAvg + "." + i}
-		res, err := l.Sess.Get(o)
-		if err != nil {
-			return fmt.Errorf("snmp error: %v", err)
-		}
-		// DEBUG
-		if l.Debug {
-			fmt.Printf("%# v\n", pretty.Formatter(res))
-		}
+	l.setLevel(0)
+	l.addPerf("uptime", fmt.Sprintf("%d", up), "s", "", "", "0", "")
+	l.addMsg(0, fmt.Sprintf("snmp reachable, uptime %v", time.Duration(up)*time.Second), "")
 
-		d := map[string]uint64{
-			"util":  res[jnxOperatingCPU+"."+i].Gauge32,
-			"load1": res[jnxOperating1MinLoadAvg+"."+i].Gauge32,
-			"load5": res[jnxOperating5MinLoadAvg+"."+i].Gauge32,
-		}
+	return nil
+}
 
-		loads[n] = d
+// Report missing components if fewer than Load.ExpectComponents cpu components were found
+func (l *Load) expectComponents(cnt int) error {
+	if l.ExpectComponents <= 0 || cnt >= l.ExpectComponents {
+		return nil
 	}
 
-	cn := make([]string, len(loads))
-	i := 0
-	for k := range loads {
-		cn[i] = k
-		i++
+	level := 1
+	switch l.ExpectLevel {
+	case "", "warning":
+	case "critical":
+		level = 2
+	default:
+		return fmt.Errorf("not valid expect level - %s", l.ExpectLevel)
 	}
-	sort.Strings(cn)
 
-	for _, n := range cn {
-		l.Check.AddMsg(0, n, "")
+	l.keeping = true
+	defer func() { l.keeping = false }()
+
+	l.setLevel(level)
+	l.addMsg(level, fmt.Sprintf("%d of %d expected cpu components found", cnt, l.ExpectComponents), "")
+
+	return nil
+}
 
-		if v, ok := loads[n]["util"]; ok {
-			level, err := l.Check.AlarmLevel(int64(v), l.Warn, l.Crit)
-			if err != nil {
-				return fmt.Errorf("alarm level error: %v", err)
-			}
-			l.Check.AddPerfData("'"+n+" util'", fmt.Sprintf("%d", v), "%", l.Warn, l.Crit, "0", "")
-			l.Check.AddMsg(level, fmt.Sprintf("util %d%%", v), "")
-		} else {
-			l.Check.AddMsg(3, "util Na", "")
-		}
+// Report spread between busiest and least busy component. Spread over
+// Load.ImbalancePct raises warning.
+func (l *Load) imbalance(utils []int64) {
+	if l.ImbalancePct <= 0 || len(utils) < 2 {
+		return
+	}
 
-		for _, t := range []string{"1", "5"} {
-			if v, ok := loads[n]["load"+t]; ok {
-				l.Check.AddPerfData("'"+n+" load"+t+"'", fmt.Sprintf("%d", v), "%", "", "", "0", "")
-				l.Check.AddMsg(0, fmt.Sprintf("load%s %d%%", t, v), "")
-			} else {
-				l.Check.AddMsg(3, "load"+t+" Na", "")
-			}
+	min, max := utils[0], utils[0]
+	for _, v := range utils {
+		if v < min {
+			min = v
+		}
+		if v > max {
+			max = v
 		}
 	}
 
-	return nil
+	l.keeping = true
+	defer func() { l.keeping = false }()
+
+	spread := max - min
+	l.addPerf("cpu_spread", fmt.Sprintf("%d", spread), "%", strconv.Itoa(l.ImbalancePct), "", "0", "100")
+	if spread > int64(l.ImbalancePct) {
+		l.setLevel(1)
+		l.addMsg(1, fmt.Sprintf("cpu imbalance %d%%", spread), "")
+	}
 }
 
-// Get Cisco load data using ciscoProcessMIB
-func (l *Load) ciscoLoad() error {
-	// Find CPU entity id-s
-	res, err := l.Sess.Walk(cpmCPUTotalPhysicalIndex, true, true)
+// Raise check return value to level. Unknown level does not override known levels.
+func (l *Load) setLevel(level int) {
+	c := l.Check.RetVal()
+	if (c == 3 && level != 3) || (c != 3 && level != 3 && level > c) {
+		l.Check.SetRetVal(level)
+	}
+}
+
+// Get Cisco SD-WAN load data. cEdge (IOS-XE) is checked as cisco, vEdge (Viptela OS)
+// using VIPTELA-OPER-SYSTEM cpu status or hrProcessorLoad average if it is not available.
+func (l *Load) sdwanLoad() error {
+	res, err := l.get([]string{sysObjectID})
 	if err != nil {
 		return fmt.Errorf("snmp error: %v", err)
 	}
@@ -362,51 +3913,74 @@ func (l *Load) ciscoLoad() error {
 		fmt.Printf("%# v\n", pretty.Formatter(res))
 	}
 
-	names := make(map[string]string)
-	cpuIDs := make(map[string]int64)
-	for i, d := range res {
-		if d.Integer == 0 {
-			names[i] = "CPU0"
-			continue
-		}
-		cpuIDs[i] = d.Integer
+	soi := res[sysObjectID].ObjectIdentifier
+	switch enterprise(soi) {
+	case "9":
+		return l.ciscoLoad()
+	case "41916":
+		return l.vEdgeLoad(soi)
 	}
 
-	// Find entity names
-	eo := make([]string, len(cpuIDs))
-	i := 0
-	for _, v := range cpuIDs {
-		eo[i] = fmt.Sprintf("%s.%d", entPhysicalName, v)
-		i++
-	}
+	l.addMsg(3, "not cEdge or vEdge device, sysObjectID "+soi, "")
 
-	res, err = l.Sess.Get(eo)
-	if err != nil {
-		return fmt.Errorf("snmp error: %v", err)
-	}
+	return nil
+}
+
+// Get Viptela OS vEdge load data. Alarm level is calculated from systemStatusCpuIdle
+// converted to utilization, user and system cpu are informational. hrProcessorLoad
+// average is used if system status cpu objects are not available.
+func (l *Load) vEdgeLoad(soi string) error {
+	o := []string{systemStatusCpuUser, systemStatusCpuSystem, systemStatusCpuIdle}
+	res, err := l.get(o)
 	// DEBUG
 	if l.Debug {
 		fmt.Printf("%# v\n", pretty.Formatter(res))
 	}
 
-	for idx, eidx := range cpuIDs {
-		oid := fmt.Sprintf("%s.%d", entPhysicalName, eidx)
-		if res[oid].OctetString != "" {
-			names[idx] = res[oid].OctetString
+	d := make(map[string]float64)
+	if err == nil {
+		for _, k := range o {
+			if v, err := decValue(res, k); err == nil {
+				d[k] = v
+			}
 		}
 	}
 
-	// Get CPU load data
-	lo := make([]string, 2*len(names))
-	i = 0
-	for idx := range names {
-		lo[i] = cpmCPUTotal1minRev + "." + idx
-		i++
-		lo[i] = cpmCPUTotal5minRev + "." + idx
-		i++
+	idle, ok := d[systemStatusCpuIdle]
+	if !ok || idle < 0 || idle > 100 {
+		res, err := l.walk(hrProcessorLoad, true, true)
+		if err == nil && len(res) > 0 {
+			return l.hostLoadData(res)
+		}
+		l.addAbsent("vEdge cpu data not available, sysObjectID " + soi)
+		return nil
+	}
+	util := 100 - idle
+	u := roundVal(util, l.RoundMode)
+
+	level, err := l.alarmLevel("cpu_usage", u, l.Warn, l.Crit)
+	if err != nil {
+		return fmt.Errorf("alarm level error: %v", err)
 	}
 
-	res, err = l.Sess.Get(lo)
+	l.addPerf("cpu_usage", l.pct(util, u), "%", l.Warn, l.Crit, "0", "100")
+	if v, ok := d[systemStatusCpuUser]; ok {
+		l.addPerf("cpu_user", l.pct(v, roundVal(v, l.RoundMode)), "%", "", "", "0", "100")
+	}
+	if v, ok := d[systemStatusCpuSystem]; ok {
+		l.addPerf("cpu_system", l.pct(v, roundVal(v, l.RoundMode)), "%", "", "", "0", "100")
+	}
+	l.addMsg(level, fmt.Sprintf("usage %d%%", u), "")
+	l.normalized(u)
+
+	return nil
+}
+
+// Get Oracle/Sun server load data. Host agents (Solaris SMA, net-snmp) are checked
+// using hrProcessorLoad average, net-snmp agents without hrProcessorTable using ssCpuIdle.
+// ILOM service processor itself has no host cpu utilization objects.
+func (l *Load) ilomLoad() error {
+	res, err := l.get([]string{sysObjectID})
 	if err != nil {
 		return fmt.Errorf("snmp error: %v", err)
 	}
@@ -415,122 +3989,130 @@ func (l *Load) ciscoLoad() error {
 		fmt.Printf("%# v\n", pretty.Formatter(res))
 	}
 
-	loads := make(map[string]map[string]uint64)
-	for idx, n := range names {
-		l1mo := cpmCPUTotal1minRev + "." + idx
-		l5mo := cpmCPUTotal5minRev + "." + idx
-
-		d := make(map[string]uint64)
-		if v, ok := res[l1mo]; ok {
-			d["l1m"] = v.Gauge32
+	soi := res[sysObjectID].ObjectIdentifier
+	switch enterprise(soi) {
+	case "42", "111", "8072":
+		res, err := l.walk(hrProcessorLoad, true, true)
+		if err == nil && len(res) > 0 {
+			err = l.hostLoadData(res)
+			if err != nil {
+				return err
+			}
+			l.coreLoads(res)
+			return nil
 		}
-		if v, ok := res[l5mo]; ok {
-			d["l5m"] = v.Gauge32
+		if enterprise(soi) == "8072" {
+			return l.cpuLoad()
 		}
-		loads[n] = d
 	}
 
-	wInt, err := strconv.Atoi(l.Warn)
+	l.addAbsent("host cpu data not available, sysObjectID " + soi)
+
+	return nil
+}
+
+// Get Versa and VeloCloud SD-WAN edge load data using hrProcessorLoad average.
+// Edges are recognized by Versa, VeloCloud or net-snmp sysObjectID.
+func (l *Load) sdwanEdgeLoad() error {
+	res, err := l.get([]string{sysObjectID})
 	if err != nil {
-		return fmt.Errorf("warning level must be integer: %v", err)
+		return fmt.Errorf("snmp error: %v", err)
+	}
+	// DEBUG
+	if l.Debug {
+		fmt.Printf("%# v\n", pretty.Formatter(res))
 	}
 
-	cInt, err := strconv.Atoi(l.Crit)
-	if err != nil {
-		return fmt.Errorf("critical level must be integer: %v", err)
+	soi := res[sysObjectID].ObjectIdentifier
+	switch enterprise(soi) {
+	case "42359", "45346", "8072":
+		res, err := l.walk(hrProcessorLoad, true, true)
+		if err == nil && len(res) > 0 {
+			return l.hostLoadData(res)
+		}
+		l.addAbsent("edge cpu data not available, sysObjectID " + soi)
+		return nil
 	}
 
-	// Calculate alarm levels for 5 min values
-	w5m := strconv.Itoa(wInt - 5)
-	c5m := strconv.Itoa(cInt - 5)
+	l.addMsg(3, "not Versa or VeloCloud edge, sysObjectID "+soi, "")
 
-	cn := make([]string, len(loads))
-	i = 0
-	for k := range loads {
-		cn[i] = k
-		i++
+	return nil
+}
+
+// Returns private enterprise number of sysObjectID or empty string if it is not under enterprises subtree
+func enterprise(soi string) string {
+	p := strings.TrimPrefix(soi, ".")
+	if !strings.HasPrefix(p, "1.3.6.1.4.1.") {
+		return ""
 	}
-	sort.Strings(cn)
 
-	for _, n := range cn {
-		l.Check.AddMsg(0, n, "")
+	return strings.SplitN(strings.TrimPrefix(p, "1.3.6.1.4.1."), ".", 2)[0]
+}
 
-		if v, ok := loads[n]["l1m"]; ok {
-			level, err := l.Check.AlarmLevel(int64(v), l.Warn, l.Crit)
-			if err != nil {
-				return fmt.Errorf("alarm level error: %v", err)
-			}
-			l.Check.AddPerfData("'"+n+" 1min'", fmt.Sprintf("%d", v), "%", l.Warn, l.Crit, "0", "")
-			l.Check.AddMsg(level, fmt.Sprintf("1m %d%%", v), "")
-		} else {
-			l.Check.AddMsg(3, "1m Na", "")
+// Returns false and reports unknown with check type hint if sysObjectID of device
+// is not under any of vendors enterprise numbers
+func (l *Load) verify(vendors []string) (bool, error) {
+	res, err := l.get([]string{sysObjectID})
+	if err != nil {
+		return false, fmt.Errorf("snmp error: %v", err)
+	}
+
+	soi := res[sysObjectID].ObjectIdentifier
+	e := enterprise(soi)
+	for _, v := range vendors {
+		if v == e {
+			return true, nil
 		}
+	}
 
-		if v, ok := loads[n]["l5m"]; ok {
-			level, err := l.Check.AlarmLevel(int64(v), w5m, c5m)
-			if err != nil {
-				return fmt.Errorf("alarm level error: %v", err)
+	var hint []string
+	for n, t := range checkTypes {
+		for _, v := range t.vendors {
+			if v == e {
+				hint = append(hint, n)
 			}
-			l.Check.AddPerfData("'"+n+" 5min'", fmt.Sprintf("%d", v), "%", w5m, c5m, "0", "")
-			l.Check.AddMsg(level, fmt.Sprintf("5m %d%%", v), "")
-		} else {
-			l.Check.AddMsg(3, "5m Na", "")
 		}
+	}
+	sort.Strings(hint)
 
-		l.Check.AddPerfData("dummy", "0", "", "", "", "", "")
+	msg := fmt.Sprintf("sysObjectID %s does not match %s check type", soi, l.Ctype)
+	if len(hint) > 0 {
+		msg += fmt.Sprintf(", looks like enterprise %s, try -t %s", e, strings.Join(hint, "|"))
 	}
+	l.addMsg(3, msg, "")
 
-	return nil
+	return false, nil
 }
 
-// Get load data using tmnxSysCpuMonCpuIdle oid
-func (l *Load) timetraLoad() error {
-	wPerc, err := strconv.Atoi(l.Warn)
+// Report unknown with sysObjectID when device has no usable cpu data source
+func (l *Load) noSource() error {
+	res, err := l.get([]string{sysObjectID})
 	if err != nil {
-		return fmt.Errorf("warning level must be integer: %v", err)
+		return fmt.Errorf("snmp error: %v", err)
 	}
 
-	cPerc, err := strconv.Atoi(l.Crit)
-	if err != nil {
-		return fmt.Errorf("critical level must be integer: %v", err)
-	}
+	l.addAbsent("no usable cpu data source, sysObjectID " + res[sysObjectID].ObjectIdentifier)
 
-	w1 := wPerc
-	c1 := cPerc
-	w60 := wPerc - 5
-	c60 := cPerc - 5
-	w300 := wPerc - 10
-	c300 := cPerc - 10
+	return nil
+}
 
-	idle := map[string]map[string]string{
-		"u1": {
-			"oid":   tmnxSysCpuMonCpuIdle + ".1",
-			"name":  "usage_1_sec",
-			"warn":  strconv.Itoa(w1 * 100),
-			"crit":  strconv.Itoa(c1 * 100),
-			"wReal": l.Warn,
-			"cReal": l.Crit,
-		},
-		"u60": {
-			"oid":   tmnxSysCpuMonCpuIdle + ".60",
-			"name":  "usage_60_sec",
-			"warn":  strconv.Itoa(w60 * 100),
-			"crit":  strconv.Itoa(c60 * 100),
-			"wReal": fmt.Sprintf("%d", w60),
-			"cReal": fmt.Sprintf("%d", c60),
-		},
-		"u300": {
-			"oid":   tmnxSysCpuMonCpuIdle + ".300",
-			"name":  "usage_300_sec",
-			"warn":  strconv.Itoa(w300 * 100),
-			"crit":  strconv.Itoa(c300 * 100),
-			"wReal": fmt.Sprintf("%d", w300),
-			"cReal": fmt.Sprintf("%d", c300),
-		},
+// Counter values saved between rate check polls
+type rateState struct {
+	Busy   uint64 `json:"busy"`
+	Total  uint64 `json:"total"`
+	Uptime uint64 `json:"uptime"`
+	Time   int64  `json:"time"`
+}
+
+// Get load data from delta of busy and total tick counters between polls
+func (l *Load) rateLoad() error {
+	oids, err := l.rateOids()
+	if err != nil {
+		return err
 	}
 
 	// Do SNMP query
-	res, err := l.Sess.Get([]string{idle["u1"]["oid"], idle["u60"]["oid"], idle["u300"]["oid"]})
+	res, err := l.get([]string{oids[0], oids[1], sysUpTime})
 	if err != nil {
 		return fmt.Errorf("snmp error: %v", err)
 	}
@@ -539,122 +4121,218 @@ func (l *Load) timetraLoad() error {
 		fmt.Printf("%# v\n", pretty.Formatter(res))
 	}
 
-	for _, p := range [3]string{"u1", "u60", "u300"} {
-		v := 10000 - int64(res[idle[p]["oid"]].Gauge32)
-
-		level, err := l.Check.AlarmLevel(v, idle[p]["warn"], idle[p]["crit"])
-		if err != nil {
-			return fmt.Errorf("alarm level error: %v", err)
-		}
+	busy, err := numValue(res, oids[0])
+	if err != nil {
+		return fmt.Errorf("busy ticks error: %v", err)
+	}
 
-		vReal := fmt.Sprintf("%.2f", float64(v)/100)
-		l.Check.AddPerfData(idle[p]["name"], vReal, "", idle[p]["wReal"], idle[p]["cReal"], "0", "")
-		l.Check.AddMsg(level, fmt.Sprintf("%s %s", p, vReal), "")
+	total, err := numValue(res, oids[1])
+	if err != nil {
+		return fmt.Errorf("total ticks error: %v", err)
 	}
 
-	return nil
-}
+	cur := rateState{
+		Busy:   uint64(busy),
+		Total:  uint64(total),
+		Uptime: res[sysUpTime].TimeTicks,
+		Time:   time.Now().Unix(),
+	}
+	var prev rateState
+	found := state.Load(l.StateDir, l.Sess.Host, l.Ctype, "counters", &prev)
 
-// Get load data using rcDeviceStsCpuUsagePercent oid
-func (l *Load) ruggedSwLoad() error {
-	// Do SNMP query
-	res, err := l.Sess.Get([]string{rcDeviceStsCpuUsagePercent})
+	err = state.Save(l.StateDir, l.Sess.Host, l.Ctype, "counters", cur)
 	if err != nil {
-		return fmt.Errorf("snmp error: %v", err)
+		return err
 	}
 	// DEBUG
 	if l.Debug {
-		fmt.Printf("%# v\n", pretty.Formatter(res))
+		fmt.Printf("%# v\n", pretty.Formatter(map[string]rateState{"prev": prev, "cur": cur}))
 	}
 
-	u := int64(res[rcDeviceStsCpuUsagePercent].Integer)
+	if !found {
+		l.addMsg(3, "first poll, no previous counter values", "")
+		return nil
+	}
+
+	// Counters are reset on reboot
+	if cur.Uptime < prev.Uptime {
+		l.addMsg(3, "sysUpTime decreased, device rebooted since last poll", "")
+		return nil
+	}
 
-	level, err := l.Check.AlarmLevel(u, l.Warn, l.Crit)
+	if cur.Busy < prev.Busy || cur.Total <= prev.Total {
+		l.addMsg(3, "counter wrap or reset, no usable delta", "")
+		return nil
+	}
+
+	util := float64(cur.Busy-prev.Busy) / float64(cur.Total-prev.Total) * 100
+	u := roundVal(util, l.RoundMode)
+
+	level, err := l.alarmLevel("cpu_usage", u, l.Warn, l.Crit)
 	if err != nil {
 		return fmt.Errorf("alarm level error: %v", err)
 	}
 
-	l.Check.AddPerfData("cpu_usage", fmt.Sprintf("%d", u), "%", l.Warn, l.Crit, "0", "100")
-	l.Check.AddPerfData("dummy1", "0", "", "", "", "", "")
-	l.Check.AddPerfData("dummy2", "0", "", "", "", "", "")
-	l.Check.AddMsg(level, fmt.Sprintf("usage %d%%", u), "")
+	l.addPerf("cpu_usage", l.pct(util, u), "%", l.Warn, l.Crit, "0", "100")
+	l.addMsg(level, fmt.Sprintf("usage %d%%", u), "")
+	l.normalized(u)
 
 	return nil
 }
 
-// Get Moxa load data using cpuLoading5s cpuLoading30s cpuLoading300s oids
-func (l *Load) moxaSwLoad() error {
-	// Get sysobjectid
-	res, err := l.Sess.Get([]string{sysObjectID})
+// Delay between retries of empty walk
+const emptyWalkDelay = 2 * time.Second
+
+// Number of polls kept in rolling baseline
+const baselineSize = 288
+
+// Minimum number of polls in baseline before it is used for alarming
+const baselineMin = 12
+
+// Cpu utilization values saved for rolling baseline
+type baselineState struct {
+	Samples []int64 `json:"samples"`
+}
+
+// Compare canonical cpu utilization with rolling baseline of host and check type.
+// Warning is raised if value exceeds baseline mean by Load.BaselineSigma stddevs.
+// Absolute levels are used alone until baseline has enough history.
+func (l *Load) baseline() error {
+	var st baselineState
+	if !state.Load(l.StateDir, l.Sess.Host, l.Ctype, "baseline", &st) {
+		st = baselineState{}
+	}
+
+	hist := st.Samples
+	st.Samples = append(append([]int64{}, hist...), l.norm)
+	if len(st.Samples) > baselineSize {
+		st.Samples = st.Samples[len(st.Samples)-baselineSize:]
+	}
+
+	err := state.Save(l.StateDir, l.Sess.Host, l.Ctype, "baseline", st)
 	if err != nil {
-		return fmt.Errorf("snmp error: %v", err)
+		return err
 	}
 	// DEBUG
 	if l.Debug {
-		fmt.Printf("%# v\n", pretty.Formatter(res))
+		fmt.Printf("%# v\n", pretty.Formatter(st))
 	}
 
-	soi := res[sysObjectID].ObjectIdentifier
+	if len(hist) < baselineMin {
+		return nil
+	}
 
-	ol5 := soi + ".1.53.0"
-	ol30 := soi + ".1.54.0"
-	ol300 := soi + ".1.55.0"
+	var sum float64
+	for _, v := range hist {
+		sum += float64(v)
+	}
+	mean := sum / float64(len(hist))
 
-	res, err = l.Sess.Get([]string{ol5, ol30, ol300})
-	if err != nil {
-		return fmt.Errorf("snmp error: %v", err)
+	var sq float64
+	for _, v := range hist {
+		sq += (float64(v) - mean) * (float64(v) - mean)
 	}
-	// DEBUG
-	if l.Debug {
-		fmt.Printf("%# v\n", pretty.Formatter(res))
+	sd := math.Sqrt(sq / float64(len(hist)))
+
+	dev := float64(l.norm) - mean
+	l.addPerf("cpu_baseline", strconv.FormatFloat(mean, 'f', 1, 64), "%", "", "", "0", "100")
+	l.addPerf("cpu_deviation", strconv.FormatFloat(dev, 'f', 1, 64), "%", "", "", "", "")
+
+	if sd > 0 && dev > l.BaselineSigma*sd {
+		l.setLevel(1)
+		l.addMsg(1, fmt.Sprintf("utilization %d%% above baseline %.1f%% by %.1f stddev", l.norm, mean, dev/sd), "")
 	}
 
-	l5 := int64(res[ol5].Integer)
-	l30 := int64(res[ol30].Integer)
-	l300 := int64(res[ol300].Integer)
+	return nil
+}
 
-	wInt, err := strconv.Atoi(l.Warn)
-	if err != nil {
-		return fmt.Errorf("warning level must be integer: %v", err)
+// Returns candidate oids of firstof check in probe order. hrProcessorLoad
+// keyword is replaced by its oid.
+func (l *Load) probeOids() ([]string, error) {
+	if strings.TrimSpace(l.ProbeOrder) == "" {
+		return nil, fmt.Errorf("firstof check requires candidate oids")
 	}
 
-	cInt, err := strconv.Atoi(l.Crit)
-	if err != nil {
-		return fmt.Errorf("critical level must be integer: %v", err)
+	oids := strings.Split(l.ProbeOrder, ",")
+	for i, o := range oids {
+		o = strings.TrimSpace(o)
+		if strings.EqualFold(o, "hrProcessorLoad") {
+			oids[i] = hrProcessorLoad
+			continue
+		}
+		oids[i] = "." + strings.TrimPrefix(o, ".")
 	}
 
-	// Calculate alarm levels for 30s and 300s values
-	w30s := strconv.Itoa(wInt - 5)
-	c30s := strconv.Itoa(cInt - 5)
-	w300s := strconv.Itoa(wInt - 10)
-	c300s := strconv.Itoa(cInt - 10)
+	return oids, nil
+}
 
-	level, err := l.Check.AlarmLevel(l5, l.Warn, l.Crit)
-	if err != nil {
-		return fmt.Errorf("alarm level error: %v", err)
+// Returns busy and total tick counter oids of rate check
+func (l *Load) rateOids() ([]string, error) {
+	oids := strings.Split(l.Rate, ",")
+	if len(oids) != 2 {
+		return nil, fmt.Errorf("rate check requires busy and total tick oids")
+	}
+	for i, o := range oids {
+		oids[i] = "." + strings.TrimPrefix(strings.TrimSpace(o), ".")
 	}
-	l.Check.AddPerfData("usage_5s", fmt.Sprintf("%d", l5), "%", l.Warn, l.Crit, "0", "100")
-	l.Check.AddMsg(level, fmt.Sprintf("usage 5s %d%%", l5), "")
 
-	level, err = l.Check.AlarmLevel(l30, w30s, c30s)
-	if err != nil {
-		return fmt.Errorf("alarm level error: %v", err)
+	return oids, nil
+}
+
+// Returns numeric value of oid from snmp result regardless of its SNMP type
+func numValue(res snmphelper.SnmpOut, oid string) (int64, error) {
+	v, ok := res[oid]
+	if !ok {
+		return 0, fmt.Errorf("no value for %s", oid)
 	}
-	l.Check.AddPerfData("usage_30s", fmt.Sprintf("%d", l30), "%", w30s, c30s, "0", "100")
-	l.Check.AddMsg(level, fmt.Sprintf("30s %d%%", l30), "")
 
-	level, err = l.Check.AlarmLevel(l300, w300s, c300s)
-	if err != nil {
-		return fmt.Errorf("alarm level error: %v", err)
+	switch v.Vtype {
+	case "Integer":
+		return v.Integer, nil
+	case "Gauge32":
+		return int64(v.Gauge32), nil
+	case "Counter32":
+		return int64(v.Counter32), nil
+	case "Counter64":
+		return int64(v.Counter64), nil
+	case "TimeTicks":
+		return int64(v.TimeTicks), nil
 	}
-	l.Check.AddPerfData("usage_300s", fmt.Sprintf("%d", l300), "%", w300s, c300s, "0", "100")
-	l.Check.AddMsg(level, fmt.Sprintf("300s %d%%", l300), "")
 
-	return nil
+	return 0, fmt.Errorf("not numeric value for %s - %s", oid, v.Vtype)
+}
+
+// Returns numeric or decimal string value of oid from snmp result
+func decValue(res snmphelper.SnmpOut, oid string) (float64, error) {
+	if v, ok := res[oid]; ok && v.Vtype == "OctetString" {
+		return strconv.ParseFloat(strings.TrimSpace(v.OctetString), 64)
+	}
+	i, err := numValue(res, oid)
+
+	return float64(i), err
+}
+
+// Returns message note for alarmed component in transitional state
+func stateNote(level int, state string) string {
+	if level == 0 || state == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(" (%s, spike expected during switchover)", state)
+}
+
+// Returns message note with temperature of alarmed component if known
+func tempNote(level int, temps map[string]uint64, n string) string {
+	v, ok := temps[n]
+	if level == 0 || !ok {
+		return ""
+	}
+
+	return fmt.Sprintf(" (temp %dC)", v)
 }
 
 // Returns load data as cpu cnt and load map
-func calcCPUData(data snmphelper.SnmpOut) (map[string]int64, error) {
+func calcCPUData(data snmphelper.SnmpOut, mode string) (map[string]int64, float64, error) {
 	var loads []int64
 
 	for _, d := range data {
@@ -663,7 +4341,7 @@ func calcCPUData(data snmphelper.SnmpOut) (map[string]int64, error) {
 
 	cnt := int64(len(loads))
 	if cnt == 0 {
-		return nil, fmt.Errorf("CPU count 0 or unknown")
+		return nil, 0, fmt.Errorf("CPU count 0 or unknown")
 	}
 
 	var loadSum int64 = 0
@@ -672,9 +4350,55 @@ func calcCPUData(data snmphelper.SnmpOut) (map[string]int64, error) {
 	}
 
 	var loadAvg float64 = float64(loadSum) / float64(cnt)
-	var load int64 = int64(math.Round(loadAvg))
+	var load int64 = roundVal(loadAvg, mode)
 
 	out := map[string]int64{"cpuCnt": cnt, "load": load}
 
+	return out, loadAvg, nil
+}
+
+// Returns percentage perfdata value. Unrounded value v is formatted with
+// Load.Precision decimals if set, otherwise rounded value i is used.
+func (l *Load) pct(v float64, i int64) string {
+	if l.Precision > 0 {
+		return strconv.FormatFloat(v, 'f', l.Precision, 64)
+	}
+
+	return fmt.Sprintf("%d", i)
+}
+
+// Returns oid suffixes parsed from comma separated string or defaults if string is empty.
+// Number of suffixes must match number of defaults.
+func parseOidSuffixes(s string, defaults []string) ([]string, error) {
+	if s == "" {
+		return defaults, nil
+	}
+
+	parts := strings.Split(s, ",")
+	if len(parts) != len(defaults) {
+		return nil, fmt.Errorf("expected %d suffixes, got %d", len(defaults), len(parts))
+	}
+
+	out := make([]string, len(parts))
+	for i, p := range parts {
+		p = strings.TrimSpace(p)
+		if !oidFragment.MatchString(p) {
+			return nil, fmt.Errorf("not valid oid fragment - %s", p)
+		}
+		out[i] = "." + strings.TrimPrefix(p, ".")
+	}
+
 	return out, nil
 }
+
+// Returns value converted to integer using round mode (round|ceil|floor). Default is round.
+func roundVal(v float64, mode string) int64 {
+	switch mode {
+	case "ceil":
+		return int64(math.Ceil(v))
+	case "floor":
+		return int64(math.Floor(v))
+	}
+
+	return int64(math.Round(v))
+}
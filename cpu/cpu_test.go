@@ -0,0 +1,601 @@
+package cpu
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aretaja/icingahelper"
+	"github.com/aretaja/snmphelper"
+	"github.com/gosnmp/gosnmp"
+)
+
+func TestNumValue(t *testing.T) {
+	res := snmphelper.SnmpOut{
+		"integer":   {Vtype: "Integer", Integer: 37},
+		"gauge":     {Vtype: "Gauge32", Gauge32: 12},
+		"counter32": {Vtype: "Counter32", Counter32: 4000},
+		"counter64": {Vtype: "Counter64", Counter64: 5000000000},
+		"string":    {Vtype: "OctetString", OctetString: "37"},
+	}
+
+	tests := []struct {
+		oid  string
+		want int64
+		err  bool
+	}{
+		{"integer", 37, false},
+		{"gauge", 12, false},
+		{"counter32", 4000, false},
+		{"counter64", 5000000000, false},
+		{"string", 0, true},
+		{"missing", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := numValue(res, tt.oid)
+		if (err != nil) != tt.err {
+			t.Errorf("numValue(%s) error = %v, want error %v", tt.oid, err, tt.err)
+		}
+		if got != tt.want {
+			t.Errorf("numValue(%s) = %d, want %d", tt.oid, got, tt.want)
+		}
+	}
+}
+
+func TestCPUCountSource(t *testing.T) {
+	sess := testAgent(t, []gosnmp.SnmpPDU{
+		{Name: hrProcessorLoad + ".196608", Type: gosnmp.Integer, Value: 10},
+		{Name: laIndex + ".1", Type: gosnmp.Integer, Value: 1},
+		{Name: laIndex + ".2", Type: gosnmp.Integer, Value: 2},
+		{Name: laIndex + ".3", Type: gosnmp.Integer, Value: 3},
+	})
+
+	tests := []struct {
+		src  string
+		want int
+		err  bool
+	}{
+		{"", 1, false},
+		{"hrprocessor", 1, false},
+		{"laTable", 3, false},
+		{"8", 8, false},
+		{"0", 0, true},
+		{"cores", 0, true},
+	}
+
+	for _, tt := range tests {
+		l := &Load{Sess: sess, CPUCountSource: tt.src}
+		got, err := l.cpuCount()
+		if (err != nil) != tt.err {
+			t.Errorf("cpuCount(%q) error = %v, want error %v", tt.src, err, tt.err)
+		}
+		if got != tt.want {
+			t.Errorf("cpuCount(%q) = %d, want %d", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestJnxAmbiguous(t *testing.T) {
+	tests := []struct {
+		name string
+		res  snmphelper.SnmpOut
+		want bool
+	}{
+		{"classic", snmphelper.SnmpOut{
+			"9.1.0.0": {Vtype: "OctetString", OctetString: "Routing Engine 0"},
+			"9.2.0.0": {Vtype: "OctetString", OctetString: "Routing Engine 1"},
+			"7.1.0.0": {Vtype: "OctetString", OctetString: "FPC: MPC7E 3D @ 0/*/*"},
+		}, false},
+		{"evo", snmphelper.SnmpOut{
+			"9.1.0.0": {Vtype: "OctetString", OctetString: "RE0"},
+			"9.2.0.0": {Vtype: "OctetString", OctetString: "RE1"},
+		}, true},
+		{"cluster", snmphelper.SnmpOut{
+			"9.1.0.0": {Vtype: "OctetString", OctetString: "Routing Engine"},
+			"9.2.0.0": {Vtype: "OctetString", OctetString: "Routing Engine"},
+		}, true},
+	}
+
+	for _, tt := range tests {
+		if got := jnxAmbiguous(tt.res); got != tt.want {
+			t.Errorf("jnxAmbiguous(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestJnxLoadIntegerCPU(t *testing.T) {
+	sess := testAgent(t, []gosnmp.SnmpPDU{
+		{Name: sysDescr, Type: gosnmp.OctetString, Value: []byte("Juniper Networks, Inc. mx480")},
+		{Name: jnxOperatingDescr + ".9.1.0.0", Type: gosnmp.OctetString, Value: []byte("Routing Engine 0")},
+		{Name: jnxOperatingDescr + ".9.2.0.0", Type: gosnmp.OctetString, Value: []byte("Routing Engine 1")},
+		{Name: jnxOperatingState + ".9.1.0.0", Type: gosnmp.Integer, Value: 2},
+		{Name: jnxOperatingState + ".9.2.0.0", Type: gosnmp.Integer, Value: 2},
+		// Integer typed values seen on some platforms
+		{Name: jnxOperatingCPU + ".9.1.0.0", Type: gosnmp.Integer, Value: 37},
+		{Name: jnxOperating1MinLoadAvg + ".9.1.0.0", Type: gosnmp.Integer, Value: 30},
+		{Name: jnxOperating5MinLoadAvg + ".9.1.0.0", Type: gosnmp.Integer, Value: 25},
+		{Name: jnxOperatingTemp + ".9.1.0.0", Type: gosnmp.Integer, Value: 45},
+		{Name: jnxOperatingBuffer + ".9.1.0.0", Type: gosnmp.Integer, Value: 60},
+		{Name: jnxOperatingCPU + ".9.2.0.0", Type: gosnmp.Gauge32, Value: uint(12)},
+		{Name: jnxOperating1MinLoadAvg + ".9.2.0.0", Type: gosnmp.Gauge32, Value: uint(10)},
+		{Name: jnxOperating5MinLoadAvg + ".9.2.0.0", Type: gosnmp.Gauge32, Value: uint(8)},
+	})
+
+	l := &Load{
+		Check:      icingahelper.NewCheck("CPU"),
+		Sess:       sess,
+		Ctype:      "jnx",
+		Warn:       "85",
+		Crit:       "95",
+		WithTemp:   true,
+		WithBuffer: true,
+	}
+	if err := l.Get(); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if v, ok := l.Utilization(); !ok || v != 37 {
+		t.Errorf("Utilization() = %d, %v, want 37, true", v, ok)
+	}
+	if c := l.Check.RetVal(); c != 0 {
+		t.Errorf("RetVal() = %d, want 0", c)
+	}
+
+	out := l.Check.FinalMsg()
+	for _, s := range []string{"'Routing Engine 0 util'=37%", "'Routing Engine 0 load5'=25%", "'Routing Engine 0 temp'=45", "'Routing Engine 0 buffer'=60%", "'Routing Engine 1 util'=12%"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("output %q does not contain %q", out, s)
+		}
+	}
+}
+
+func TestJnxCrossCheckKeepsPerfData(t *testing.T) {
+	sess := testAgent(t, []gosnmp.SnmpPDU{
+		{Name: ssCpuRawIdle, Type: gosnmp.Integer, Value: 90},
+		{Name: jnxOperatingDescr + ".9.1.0.0", Type: gosnmp.OctetString, Value: []byte("Routing Engine 0")},
+		{Name: jnxOperatingCPU + ".9.1.0.0", Type: gosnmp.Gauge32, Value: uint(97)},
+		{Name: jnxOperating1MinLoadAvg + ".9.1.0.0", Type: gosnmp.Gauge32, Value: uint(96)},
+		{Name: jnxOperating5MinLoadAvg + ".9.1.0.0", Type: gosnmp.Gauge32, Value: uint(95)},
+	})
+
+	l := &Load{
+		Check:          icingahelper.NewCheck("CPU"),
+		Sess:           sess,
+		Ctype:          "jnx",
+		Warn:           "85",
+		Crit:           "95",
+		IncludeOffline: true,
+		CrossCheck:     10,
+	}
+	if err := l.Get(); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if c := l.Check.RetVal(); c != 3 {
+		t.Errorf("RetVal() = %d, want 3", c)
+	}
+
+	out := l.Check.FinalMsg()
+	for _, s := range []string{"differs from 100-idle 10%", "'Routing Engine 0 util'=97%;;;0;", "'Routing Engine 0 load1'=96%", "'Routing Engine 0 load5'=95%", "'Routing Engine 0 util idle'=10%"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("output %q does not contain %q", out, s)
+		}
+	}
+	if strings.Contains(out, "(c)") {
+		t.Errorf("output %q has alarm of diverged routing engine", out)
+	}
+}
+
+func TestAggregateKeepsCheckLevelOutput(t *testing.T) {
+	sess := testAgent(t, []gosnmp.SnmpPDU{
+		{Name: jnxOperatingDescr + ".9.1.0.0", Type: gosnmp.OctetString, Value: []byte("Routing Engine 0")},
+		{Name: jnxOperatingDescr + ".9.2.0.0", Type: gosnmp.OctetString, Value: []byte("Routing Engine 1")},
+		{Name: jnxOperatingCPU + ".9.1.0.0", Type: gosnmp.Gauge32, Value: uint(70)},
+		{Name: jnxOperating1MinLoadAvg + ".9.1.0.0", Type: gosnmp.Gauge32, Value: uint(70)},
+		{Name: jnxOperating5MinLoadAvg + ".9.1.0.0", Type: gosnmp.Gauge32, Value: uint(70)},
+		{Name: jnxOperatingCPU + ".9.2.0.0", Type: gosnmp.Gauge32, Value: uint(10)},
+		{Name: jnxOperating1MinLoadAvg + ".9.2.0.0", Type: gosnmp.Gauge32, Value: uint(10)},
+		{Name: jnxOperating5MinLoadAvg + ".9.2.0.0", Type: gosnmp.Gauge32, Value: uint(10)},
+	})
+
+	l := &Load{
+		Check:            icingahelper.NewCheck("CPU"),
+		Sess:             sess,
+		Ctype:            "jnx",
+		Warn:             "85",
+		Crit:             "95",
+		IncludeOffline:   true,
+		Aggregate:        true,
+		ImbalancePct:     20,
+		ExpectComponents: 3,
+	}
+	if err := l.Get(); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if c := l.Check.RetVal(); c != 1 {
+		t.Errorf("RetVal() = %d, want 1", c)
+	}
+
+	out := l.Check.FinalMsg()
+	for _, s := range []string{"2 of 3 expected cpu components found(w)", "cpu imbalance 60%(w)", "cpu_spread=60%;20;;0;100", "cpu_max=70%"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("output %q does not contain %q", out, s)
+		}
+	}
+}
+
+func TestAggregateAlarmsOnMaxOnly(t *testing.T) {
+	sess := testAgent(t, []gosnmp.SnmpPDU{
+		{Name: cpmCPUTotalPhysicalIndex + ".1", Type: gosnmp.Integer, Value: 1000},
+		{Name: cpmCPUTotalPhysicalIndex + ".2", Type: gosnmp.Integer, Value: 2000},
+		{Name: entPhysicalName + ".1000", Type: gosnmp.OctetString, Value: []byte("CPU A")},
+		{Name: entPhysicalName + ".2000", Type: gosnmp.OctetString, Value: []byte("CPU B")},
+		{Name: cpmCPUTotal1minRev + ".1", Type: gosnmp.Gauge32, Value: uint(50)},
+		{Name: cpmCPUTotal5minRev + ".1", Type: gosnmp.Gauge32, Value: uint(97)},
+		{Name: cpmCPUTotal1minRev + ".2", Type: gosnmp.Gauge32, Value: uint(40)},
+		{Name: cpmCPUTotal5minRev + ".2", Type: gosnmp.Gauge32, Value: uint(40)},
+	})
+
+	l := &Load{
+		Check:     icingahelper.NewCheck("CPU"),
+		Sess:      sess,
+		Ctype:     "cisco",
+		Warn:      "85",
+		Crit:      "95",
+		Aggregate: true,
+	}
+	if err := l.Get(); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if c := l.Check.RetVal(); c != 0 {
+		t.Errorf("RetVal() = %d, want 0 from aggregated max", c)
+	}
+	out := l.Check.FinalMsg()
+	if !strings.Contains(out, "max 50%") || !strings.Contains(out, "cpu_max=50%;85;95;0;100") {
+		t.Errorf("output %q does not contain aggregated max 50%%", out)
+	}
+}
+
+func TestCiscoStackNames(t *testing.T) {
+	pdus := []gosnmp.SnmpPDU{
+		{Name: entPhysicalContainedIn + ".1", Type: gosnmp.Integer, Value: 0},
+		{Name: entPhysicalContainedIn + ".100", Type: gosnmp.Integer, Value: 1},
+		{Name: entPhysicalContainedIn + ".200", Type: gosnmp.Integer, Value: 1},
+		{Name: entPhysicalContainedIn + ".1000", Type: gosnmp.Integer, Value: 100},
+		{Name: entPhysicalContainedIn + ".2000", Type: gosnmp.Integer, Value: 200},
+		{Name: entPhysicalClass + ".1", Type: gosnmp.Integer, Value: 11},
+		{Name: entPhysicalClass + ".100", Type: gosnmp.Integer, Value: 3},
+		{Name: entPhysicalClass + ".200", Type: gosnmp.Integer, Value: 3},
+		{Name: entPhysicalParentRelPos + ".100", Type: gosnmp.Integer, Value: 1},
+		{Name: entPhysicalParentRelPos + ".200", Type: gosnmp.Integer, Value: 2},
+		{Name: cpmCPUTotalPhysicalIndex + ".1", Type: gosnmp.Integer, Value: 1000},
+		{Name: cpmCPUTotalPhysicalIndex + ".2", Type: gosnmp.Integer, Value: 2000},
+		{Name: entPhysicalName + ".1000", Type: gosnmp.OctetString, Value: []byte("CPU of Switch 1")},
+		{Name: entPhysicalName + ".2000", Type: gosnmp.OctetString, Value: []byte("CPU of Switch 2")},
+		{Name: cpmCPUTotal1minRev + ".1", Type: gosnmp.Gauge32, Value: uint(10)},
+		{Name: cpmCPUTotal5minRev + ".1", Type: gosnmp.Gauge32, Value: uint(10)},
+		{Name: cpmCPUTotal1minRev + ".2", Type: gosnmp.Gauge32, Value: uint(20)},
+		{Name: cpmCPUTotal5minRev + ".2", Type: gosnmp.Gauge32, Value: uint(20)},
+	}
+
+	for _, stack := range []bool{false, true} {
+		l := &Load{
+			Check:      icingahelper.NewCheck("CPU"),
+			Sess:       testAgent(t, pdus),
+			Ctype:      "cisco",
+			Warn:       "85",
+			Crit:       "95",
+			StackNames: stack,
+		}
+		if err := l.Get(); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+
+		out := l.Check.FinalMsg()
+		if got := strings.Contains(out, "'switch2 1min'=20%"); got != stack {
+			t.Errorf("StackNames %v: output %q has switch2 label %v", stack, out, got)
+		}
+	}
+}
+
+func TestTemplateRenderedLast(t *testing.T) {
+	sess := testAgent(t, []gosnmp.SnmpPDU{
+		{Name: hrProcessorLoad + ".196608", Type: gosnmp.Integer, Value: 97},
+		{Name: hrProcessorLoad + ".196609", Type: gosnmp.Integer, Value: 97},
+	})
+
+	l := &Load{
+		Check:             icingahelper.NewCheck("CPU"),
+		Sess:              sess,
+		Ctype:             "host",
+		Warn:              "85",
+		Crit:              "95",
+		AlarmOnNormalized: true,
+		Template:          "load {{.Load}}%{{range .Messages}} [{{.}}]{{end}}",
+	}
+	if err := l.Get(); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if c := l.Check.RetVal(); c != 2 {
+		t.Errorf("RetVal() = %d, want 2", c)
+	}
+
+	out := l.Check.FinalMsg()
+	if !strings.Contains(out, "load 97%") || !strings.Contains(out, "[utilization 97%]") || !strings.Contains(out, "(c)") {
+		t.Errorf("output %q is not critical template message with normalized utilization", out)
+	}
+}
+
+func TestDeadbandKeyedByName(t *testing.T) {
+	dir := t.TempDir()
+	run := func(pdus []gosnmp.SnmpPDU) int {
+		l := &Load{
+			Check:    icingahelper.NewCheck("CPU"),
+			Sess:     testAgent(t, pdus),
+			Ctype:    "jnx",
+			Warn:     "85",
+			Crit:     "95",
+			Deadband: 5,
+			StateDir: dir,
+		}
+		if err := l.Get(); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		return l.Check.RetVal()
+	}
+
+	run([]gosnmp.SnmpPDU{
+		{Name: jnxOperatingDescr + ".9.1.0.0", Type: gosnmp.OctetString, Value: []byte("Routing Engine 0")},
+		{Name: jnxOperatingDescr + ".9.2.0.0", Type: gosnmp.OctetString, Value: []byte("Routing Engine 1")},
+		{Name: jnxOperatingCPU + ".9.1.0.0", Type: gosnmp.Gauge32, Value: uint(10)},
+		{Name: jnxOperating1MinLoadAvg + ".9.1.0.0", Type: gosnmp.Gauge32, Value: uint(10)},
+		{Name: jnxOperating5MinLoadAvg + ".9.1.0.0", Type: gosnmp.Gauge32, Value: uint(10)},
+		{Name: jnxOperatingCPU + ".9.2.0.0", Type: gosnmp.Gauge32, Value: uint(90)},
+		{Name: jnxOperating1MinLoadAvg + ".9.2.0.0", Type: gosnmp.Gauge32, Value: uint(90)},
+		{Name: jnxOperating5MinLoadAvg + ".9.2.0.0", Type: gosnmp.Gauge32, Value: uint(90)},
+	})
+
+	// Routing Engine 0 is gone and Routing Engine 1 is first component now
+	c := run([]gosnmp.SnmpPDU{
+		{Name: jnxOperatingDescr + ".9.2.0.0", Type: gosnmp.OctetString, Value: []byte("Routing Engine 1")},
+		{Name: jnxOperatingCPU + ".9.2.0.0", Type: gosnmp.Gauge32, Value: uint(83)},
+		{Name: jnxOperating1MinLoadAvg + ".9.2.0.0", Type: gosnmp.Gauge32, Value: uint(83)},
+		{Name: jnxOperating5MinLoadAvg + ".9.2.0.0", Type: gosnmp.Gauge32, Value: uint(83)},
+	})
+	if c != 1 {
+		t.Errorf("RetVal() = %d, want 1 held by deadband", c)
+	}
+}
+
+func TestNetgearLoad(t *testing.T) {
+	sess := testAgent(t, []gosnmp.SnmpPDU{
+		{Name: fpSwitchCpuProcessTotalUtilization + ".0", Type: gosnmp.OctetString, Value: []byte("    5 Secs ( 12.3456%)   60 Secs ( 88.2311%)  300 Secs (  9.8765%)")},
+	})
+
+	l := &Load{
+		Check: icingahelper.NewCheck("CPU"),
+		Sess:  sess,
+		Ctype: "netgear",
+		Warn:  "85",
+		Crit:  "95",
+	}
+	if err := l.Get(); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if c := l.Check.RetVal(); c != 1 {
+		t.Errorf("RetVal() = %d, want 1", c)
+	}
+
+	out := l.Check.FinalMsg()
+	for _, s := range []string{"usage 60s 88%", "usage_60s=88%;85;95;0;100", "usage_5s=12%;;;0;100", "usage_300s=10%;;;0;100"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("output %q does not contain %q", out, s)
+		}
+	}
+}
+
+func TestNetgearStackLoad(t *testing.T) {
+	sess := testAgent(t, []gosnmp.SnmpPDU{
+		{Name: agentSwitchCpuProcessTotalUtilization + ".1", Type: gosnmp.OctetString, Value: []byte("    5 Secs ( 20.0000%)   60 Secs ( 30.4000%)  300 Secs ( 25.0000%)")},
+		{Name: agentSwitchCpuProcessTotalUtilization + ".2", Type: gosnmp.OctetString, Value: []byte("    5 Secs ( 99.0000%)   60 Secs ( 96.0000%)  300 Secs ( 90.0000%)")},
+	})
+
+	l := &Load{
+		Check: icingahelper.NewCheck("CPU"),
+		Sess:  sess,
+		Ctype: "netgear",
+		Warn:  "85",
+		Crit:  "95",
+	}
+	if err := l.Get(); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if c := l.Check.RetVal(); c != 2 {
+		t.Errorf("RetVal() = %d, want 2", c)
+	}
+
+	out := l.Check.FinalMsg()
+	for _, s := range []string{"unit1 usage 60s 30%", "unit2 usage 60s 96%(c)", "'unit1 60s'=30%;85;95;0;100", "'unit1 5s'=20%;;;0;100", "'unit2 60s'=96%;85;95;0;100", "'unit2 300s'=90%;;;0;100"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("output %q does not contain %q", out, s)
+		}
+	}
+	if v, ok := l.Utilization(); !ok || v != 96 {
+		t.Errorf("Utilization() = %d, %v, want 96, true", v, ok)
+	}
+}
+
+func TestSdwanVEdgeLoad(t *testing.T) {
+	tests := []struct {
+		name string
+		pdus []gosnmp.SnmpPDU
+		want []string
+	}{
+		{"system status", []gosnmp.SnmpPDU{
+			{Name: sysObjectID, Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.4.1.41916.3.2.1"},
+			{Name: systemStatusCpuUser, Type: gosnmp.OctetString, Value: []byte("61.50")},
+			{Name: systemStatusCpuSystem, Type: gosnmp.OctetString, Value: []byte("26.10")},
+			{Name: systemStatusCpuIdle, Type: gosnmp.OctetString, Value: []byte("12.40")},
+			{Name: hrProcessorLoad + ".196608", Type: gosnmp.Integer, Value: 5},
+		}, []string{"usage 88%", "cpu_usage=88%;85;95;0;100", "cpu_user=62%", "cpu_system=26%"}},
+		{"hrProcessorLoad", []gosnmp.SnmpPDU{
+			{Name: sysObjectID, Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.4.1.41916.3.2.1"},
+			{Name: hrProcessorLoad + ".196608", Type: gosnmp.Integer, Value: 40},
+			{Name: hrProcessorLoad + ".196609", Type: gosnmp.Integer, Value: 20},
+		}, []string{"load 30%"}},
+	}
+
+	for _, tt := range tests {
+		l := &Load{
+			Check: icingahelper.NewCheck("CPU"),
+			Sess:  testAgent(t, tt.pdus),
+			Ctype: "sdwan",
+			Warn:  "85",
+			Crit:  "95",
+		}
+		if err := l.Get(); err != nil {
+			t.Fatalf("%s: Get() error = %v", tt.name, err)
+		}
+
+		out := l.Check.FinalMsg()
+		for _, s := range tt.want {
+			if !strings.Contains(out, s) {
+				t.Errorf("%s: output %q does not contain %q", tt.name, out, s)
+			}
+		}
+	}
+}
+
+func TestSchemaVersionLabel(t *testing.T) {
+	sess := testAgent(t, []gosnmp.SnmpPDU{
+		{Name: hrProcessorLoad + ".196608", Type: gosnmp.Integer, Value: 10},
+	})
+
+	l := &Load{
+		Check:      icingahelper.NewCheck("CPU"),
+		Sess:       sess,
+		Ctype:      "host",
+		Warn:       "85",
+		Crit:       "95",
+		Prefix:     "10.0.0.1",
+		LabelStyle: "snake",
+		Schema:     true,
+	}
+	if err := l.Get(); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	want := fmt.Sprintf("10_0_0_1_schema_version=%d", schemaVersion+snakeSchema)
+	if out := l.Check.FinalMsg(); !strings.Contains(out, want) {
+		t.Errorf("output %q does not contain %q", out, want)
+	}
+}
+
+// Starts SNMPv2c agent answering get, getnext and getbulk requests with pdus
+// and returns session connected to it
+func testAgent(t *testing.T, pdus []gosnmp.SnmpPDU) *snmphelper.Session {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("agent error: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	vals := make(map[string]gosnmp.SnmpPDU)
+	var oids []string
+	for _, p := range pdus {
+		vals[p.Name] = p
+		oids = append(oids, p.Name)
+	}
+	sort.Slice(oids, func(i, j int) bool { return oidLess(oids[i], oids[j]) })
+
+	// Returns up to n pdus following oid
+	next := func(oid string, n int) []gosnmp.SnmpPDU {
+		var out []gosnmp.SnmpPDU
+		for _, o := range oids {
+			if oidLess(oid, o) && len(out) < n {
+				out = append(out, vals[o])
+			}
+		}
+		if len(out) == 0 {
+			out = append(out, gosnmp.SnmpPDU{Name: oid, Type: gosnmp.EndOfMibView})
+		}
+		return out
+	}
+
+	go func() {
+		dec := &gosnmp.GoSNMP{Version: gosnmp.Version2c, Community: "public"}
+		buf := make([]byte, 65535)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			req, err := dec.SnmpDecodePacket(buf[:n])
+			if err != nil {
+				continue
+			}
+
+			var vars []gosnmp.SnmpPDU
+			for _, v := range req.Variables {
+				switch req.PDUType {
+				case gosnmp.GetRequest:
+					if p, ok := vals[v.Name]; ok {
+						vars = append(vars, p)
+					} else {
+						vars = append(vars, gosnmp.SnmpPDU{Name: v.Name, Type: gosnmp.NoSuchInstance})
+					}
+				case gosnmp.GetNextRequest:
+					vars = append(vars, next(v.Name, 1)...)
+				case gosnmp.GetBulkRequest:
+					vars = append(vars, next(v.Name, int(req.MaxRepetitions))...)
+				}
+			}
+
+			resp := &gosnmp.SnmpPacket{
+				Version:   gosnmp.Version2c,
+				Community: req.Community,
+				PDUType:   gosnmp.GetResponse,
+				RequestID: req.RequestID,
+				Variables: vars,
+			}
+			b, err := resp.MarshalMsg()
+			if err != nil {
+				continue
+			}
+			conn.WriteTo(b, addr)
+		}
+	}()
+
+	session := snmphelper.Session{Host: "127.0.0.1", Ver: 2, User: "public"}
+	sess, err := session.New()
+	if err != nil {
+		t.Fatalf("session error: %v", err)
+	}
+	sess.Snmp.Port = uint16(conn.LocalAddr().(*net.UDPAddr).Port)
+
+	return sess
+}
+
+// Returns true if numeric oid a sorts before b
+func oidLess(a, b string) bool {
+	as := strings.Split(strings.Trim(a, "."), ".")
+	bs := strings.Split(strings.Trim(b, "."), ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		x, _ := strconv.Atoi(as[i])
+		y, _ := strconv.Atoi(bs[i])
+		if x != y {
+			return x < y
+		}
+	}
+
+	return len(as) < len(bs)
+}
@@ -6,29 +6,255 @@
 package main
 
 import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
 	"flag"
 	"fmt"
 	"net"
 	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
 
 	"github.com/aretaja/check-gosnmp-cpu/cpu"
+	"github.com/aretaja/check-gosnmp-cpu/state"
 	"github.com/aretaja/icingahelper"
 	"github.com/aretaja/snmphelper"
+	"github.com/gosnmp/gosnmp"
 )
 
 // Version of release
 const Version = "1.1.0"
 
+// Returns graphite metric path node made of s
+func graphiteName(s string) string {
+	return strings.NewReplacer(".", "_", " ", "_", ":", "_", "/", "_").Replace(s)
+}
+
+// Sets flags from -config file. Every non empty line which is not a comment (#)
+// is "<option>=<value>" where option is flag name without dash. Flags in cli
+// are not changed.
+func applyConfig(path string, cli map[string]bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("config file error: %v", err)
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("config file error: %s line %d - not valid entry %q", path, n, line)
+		}
+		k := strings.TrimLeft(strings.TrimSpace(kv[0]), "-")
+		v := strings.TrimSpace(kv[1])
+		if flag.Lookup(k) == nil || k == "config" {
+			return fmt.Errorf("config file error: %s line %d - unknown option %q", path, n, k)
+		}
+		if cli[k] {
+			continue
+		}
+		if err := flag.Set(k, v); err != nil {
+			return fmt.Errorf("config file error: %s line %d - option %s: %v", path, n, k, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("config file error: %v", err)
+	}
+
+	return nil
+}
+
+// Credential overrides of -host-file by host. Values are username or community,
+// authentication and privacy pass phrase. Empty value keeps cli argument.
+type hostCreds map[string][3]string
+
+// Reads -host-file. Every non empty line which is not a comment (#) is
+// "<host> [<username|community> [<auth pass phrase> [<privacy pass phrase>]]]".
+func readHosts(path string) ([]string, hostCreds, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("host file error: %v", err)
+	}
+	defer f.Close()
+
+	var hosts []string
+	creds := make(hostCreds)
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) > 4 {
+			return nil, nil, fmt.Errorf("host file error: %s line %d - not valid entry %q", path, n, line)
+		}
+		if _, ok := creds[fields[0]]; ok {
+			return nil, nil, fmt.Errorf("host file error: %s line %d - duplicate host %q", path, n, fields[0])
+		}
+		var c [3]string
+		copy(c[:], fields[1:])
+		creds[fields[0]] = c
+		hosts = append(hosts, fields[0])
+	}
+	if err := sc.Err(); err != nil {
+		return nil, nil, fmt.Errorf("host file error: %v", err)
+	}
+	if len(hosts) == 0 {
+		return nil, nil, fmt.Errorf("host file error: %s - no hosts", path)
+	}
+
+	return hosts, creds, nil
+}
+
+// Warning and critical levels of -thresholds-file by "<host>" or "<host> <type>"
+type fileLevels map[string][2]string
+
+// Reads -thresholds-file. Every non empty line which is not a comment (#) is
+// "<host> [<type>] <warning>/<critical>".
+func readThresholds(path string) (fileLevels, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("thresholds file error: %v", err)
+	}
+	defer f.Close()
+
+	out := make(fileLevels)
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		wc := strings.Split(fields[len(fields)-1], "/")
+		if len(fields) < 2 || len(fields) > 3 || len(wc) != 2 || wc[0] == "" || wc[1] == "" {
+			return nil, fmt.Errorf("thresholds file error: %s line %d - not valid entry %q", path, n, line)
+		}
+		if net.ParseIP(fields[0]) == nil {
+			return nil, fmt.Errorf("thresholds file error: %s line %d - not valid host ip %q", path, n, fields[0])
+		}
+		out[strings.Join(fields[:len(fields)-1], " ")] = [2]string{wc[0], wc[1]}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("thresholds file error: %v", err)
+	}
+
+	return out, nil
+}
+
+// Returns levels of host and check type. Entry of host and type is preferred
+// to entry of host only.
+func (f fileLevels) levels(host, ctype string) ([2]string, bool) {
+	if v, ok := f[host+" "+ctype]; ok {
+		return v, true
+	}
+	v, ok := f[host]
+	return v, ok
+}
+
+// Returns plugin output formatted by format. With nagios trailing spaces and
+// empty lines are removed and output ends with exactly one newline. With raw
+// output is returned unchanged.
+func formatOutput(out, format string) string {
+	if format == "raw" {
+		return out
+	}
+
+	var lines []string
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Result of polled host
+type hostResult struct {
+	load *cpu.Load // nil if session setup failed
+	note string    // message of privacy protocol fallback not yet reported
+	err  error
+}
+
+// Check result saved for -min-interval
+type lastResult struct {
+	Time   int64  `json:"time"`
+	Output string `json:"output"`
+	RetVal int    `json:"retval"`
+}
+
+// Check return values of snmp timeout by -timeout-status
+var timeoutLevels = map[string]int{
+	"unknown":  3,
+	"critical": 2,
+}
+
+// Returns true if error is snmp timeout after all retries or host refused
+// snmp request
+func timedOut(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	for _, s := range []string{"request timeout", "error reading from socket", "connection refused"} {
+		if strings.Contains(err.Error(), s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns state file host key of checked hosts. Multiple hosts (-H list or
+// -host-file) are keyed by hash of the whole list.
+func stateHost(hosts []string) string {
+	if len(hosts) == 1 {
+		return hosts[0]
+	}
+	sum := sha1.Sum([]byte(strings.Join(hosts, ",")))
+
+	return "hosts-" + hex.EncodeToString(sum[:8])
+}
+
+// Alternative variants of privacy protocols tried with -priv-fallback
+var privVariants = map[string]string{
+	"AES192":  "AES192C",
+	"AES192C": "AES192",
+	"AES256":  "AES256C",
+	"AES256C": "AES256",
+}
+
 func main() {
 	// Parse cli arguments
-	var host = flag.String("H", "", "<host ip>")
+	var host = flag.String("H", "", "<host ip>[,<host ip>...] Comma separated list checks all hosts with same parameters")
 	var snmpVer = flag.Int("V", 2, "[snmp version] (1|2|3)")
 	var snmpUser = flag.String("u", "public", "[username|community]")
 	var snmpProt = flag.String("a", "MD5", "[authentication protocol] (NoAuth|MD5|SHA)5")
 	var snmpPass = flag.String("A", "", "[authentication protocol pass phrase]")
 	var snmpSlevel = flag.String("l", "authPriv", "[security level] (noAuthNoPriv|authNoPriv|authPriv)")
 	var snmpPrivProt = flag.String("x", "DES", "[privacy protocol] (NoPriv|DES|AES|AES192|AES256|AES192C|AES256C)")
+	var privFallback = flag.Bool("priv-fallback", false, "Using this parameter will retry failed SNMPv3 session with alternative AES192/AES256 privacy protocol variant (fe. AES256C for AES256)")
 	var snmpPrivPass = flag.String("X", "", "[privacy protocol pass phrase]")
+	var authProbe = flag.String("auth-probe", "", "[auth/priv,...] SNMPv3 authentication and privacy protocol combinations (fe. SHA/AES,MD5/DES) tried until one works.\n"+
+		"\tWorking combination is kept in state file of host and tried first on next check. Explicitly set -a or -x pins its protocol",
+	)
+	var engineID = flag.String("engine-id", "", "[hex string] Authoritative SNMPv3 engine ID (fe. 80001f8880e9630000d61ff449) of agents not supporting discovery.\n"+
+		"\tEngine ID is discovered if not set",
+	)
 	var warn = flag.String("w", "85", "[warning level]. It depends of check type.\n"+
 		"\thost - % of average cpu utilization of all cores\n"+
 		"\tsystat - % of cpu utilization\n"+
@@ -37,84 +263,729 @@ func main() {
 		"\tjnx - % of cpu utilization\n"+
 		"\tcisco - overall cpu busy % in the last 1 minute period\n"+
 		"\t\t5 minute level will be calculated from this value by decreasing value by 5\n"+
+		"\tiosxr - as cisco, alarm level is calculated from route processor cpus only\n"+
+		"\tcmts - as cisco, alarm level is calculated from supervisor cpus only\n"+
+		"\tciscosb - cpu utilization % in the last 1 minute period\n"+
+		"\t\t5 minute perfdata level will be calculated from this value by decreasing value by 5\n"+
 		"\ttimetra - overall cpu busy % in the last 1 sec period\n"+
 		"\t\t1 and 5 minute level will be calculated from this value by decreasing value by 5 accordingly\n"+
 		"\trcsw - % of cpu utilization\n"+
+		"\tmeraki - % of average cpu utilization\n"+
+		"\tforti - % of cpu utilization. With -ha % of cpu utilization of busiest cluster member\n"+
+		"\tsupermicro - % of average cpu utilization of all cores\n"+
+		"\tsdwan - as cisco on cEdge, % of cpu utilization on vEdge\n"+
+		"\tsdwan-versa, velocloud - % of average cpu utilization of all cores\n"+
+		"\tendpoint - % of average cpu utilization of all cores. Default 70\n"+
+		"\topnsense - % of average cpu utilization of all cores. Default 80\n"+
+		"\ttplink - % of cpu utilization in the last 1 minute period of every stack unit or % of average cpu utilization of all cores\n"+
+		"\tarubainstant - % of cpu utilization of busiest cluster AP\n"+
+		"\tinfoblox - % of cpu utilization\n"+
+		"\tnetgear - % of cpu utilization in the last 60 sec period of every stack unit or % of average cpu utilization of all cores\n"+
+		"\tilom - as host or systat depending on data source\n"+
+		"\tbrocadefc - % of cpu utilization\n"+
+		"\trouter-linux - as systat, as host if systemStats is not available\n"+
+		"\tmikrotik - % of average cpu utilization of all cores\n"+
+		"\tappliance - % of cpu utilization. Default 90\n"+
+		"\tfirstof - % of cpu utilization of first valid -probe-order candidate\n"+
 		"\tmoxasw - overall cpu busy % in the last 5 sec period\n"+
-		"\t\t30 sec and 5 minute levels will be calculated from this value by decreasing value by 5 and 10 accordingly",
+		"\t\t30 sec and 5 minute levels will be calculated from this value by decreasing value by 5 and 10 accordingly\n"+
+		"\tComma separated per interval levels (fe. 85,80,75 for moxasw 5s,30s,300s) of loadavg, cisco, iosxr, cmts,\n"+
+		"\tciscosb, timetra and moxasw override calculated levels. Number of levels must match number of intervals",
 	)
-	var crit = flag.String("c", "95", "[critical level]. Look at warning level explanation")
+	var crit = flag.String("c", "95", "[critical level]. Look at warning level explanation. Default of endpoint is 85, opnsense 90, appliance 98")
 	var ctype = flag.String("t", "", "<check type>\n"+
 		"\thost - uses hostmib\n"+
 		"\tsysstats - uses UCD-SNMP-MIB systemStats\n"+
 		"\tloadavg - uses UCD-SNMP-MIB laTable\n"+
 		"\tjnx - uses jnxOperatingTable\n"+
 		"\tcisco - uses ciscoProcessMIB\n"+
+		"\tiosxr - uses ciscoProcessMIB, line card cpus are informational\n"+
+		"\tcmts - uses ciscoProcessMIB of cBR-8, supervisor cpus first, DOCSIS line card cpus are informational\n"+
+		"\tciscosb - uses rlCpuUtilDuringLast* from Cisco Small Business MIB\n"+
 		"\ttimetra - uses tmnxSysCpuMonTable from TIMETRA-SYSTEM-MIB\n"+
 		"\trcsw - uses rcDeviceStsCpuUsagePercent\n"+
-		"\tmoxasw - uses moxa MIB",
+		"\tmoxasw - uses moxa MIB\n"+
+		"\tmeraki - uses hrProcessorLoad average or UCD-SNMP-MIB systemStats, whichever is available\n"+
+		"\tforti - uses fgSysCpuUsage from FORTINET-FORTIGATE-MIB\n"+
+		"\treachable - checks only snmp reachability using sysUpTime\n"+
+		"\tsupermicro - uses hrProcessorLoad average, per core loads and UCD-SNMP-MIB laTable\n"+
+		"\tsdwan - uses ciscoProcessMIB on cEdge and VIPTELA-OPER-SYSTEM systemStatusCpuIdle or hrProcessorLoad average on vEdge\n"+
+		"\tsdwan-versa, velocloud - uses hrProcessorLoad average of Versa and VeloCloud edges\n"+
+		"\tendpoint - uses hrProcessorLoad average of VoIP gateways, SBCs and other appliances\n"+
+		"\topnsense - uses hrProcessorLoad average, per core loads and pf state table usage from BEGEMOT-PF-MIB\n"+
+		"\ttplink - uses tpSysMonitorCpu1Minute of TP-Link JetStream and Omada devices or hrProcessorLoad average, whichever is available\n"+
+		"\tarubainstant - uses aiAPCPUUtilization of every Aruba Instant cluster AP from virtual controller\n"+
+		"\tinfoblox - uses ibSystemMonitorCpuUsage of Infoblox NIOS grid members and per core hrProcessorLoad if available\n"+
+		"\tnetgear - uses agentSwitchCpuProcessTotalUtilization of Netgear FASTPATH switches or hrProcessorLoad average, whichever is available\n"+
+		"\tilom - uses hrProcessorLoad average or ssCpuIdle of Oracle/Sun server host agent\n"+
+		"\tbrocadefc - uses swCpuUsage from Brocade SW-MIB\n"+
+		"\trouter-linux - uses UCD-SNMP-MIB systemStats, per core hrProcessorLoad and laTable scaled by core count\n"+
+		"\tmikrotik - uses hrProcessorLoad, per core loads on multi core and UCD-SNMP-MIB laTable on single core devices\n"+
+		"\tappliance - uses oid submitted with -appliance-oid or hrProcessorLoad average of management appliances and PDUs\n"+
+		"\tfirstof - uses first of -probe-order candidate oids returning valid cpu utilization\n"+
+		"\trate - uses delta of tick counters submitted with -rate between polls",
+	)
+	var moxaSuffix = flag.String("moxa-suffix", "", "[moxasw cpu load oid suffixes] (5s,30s,300s)\n"+
+		"\tComma separated suffixes relative to sysObjectID. Default is 1.53.0,1.54.0,1.55.0",
+	)
+	var sourceAddr = flag.String("source-addr", "", "[ip address] Local source address of snmp requests. Default is chosen by system")
+	var maxOids = flag.Int("max-oids", 30, "[number of oids] Snmp get requests are split into chunks of at most this many oids. 0 disables splitting")
+	var retryEmpty = flag.Int("retry-empty", 0, "[number of retries] Empty snmp walks (fe. after agent restart) are retried this many times with 2s delay")
+	var timeoutStatus = flag.String("timeout-status", "unknown", "[level of snmp timeout] (unknown|critical) Check level when all snmp retries of host are exhausted or host refuses snmp requests")
+	var concurrency = flag.Int("concurrency", 4, "[number of hosts] Maximum number of hosts of -H list and -host-file polled at the same time.\n"+
+		"\tHost level only, jnx routing engine and cisco cpu queries of one host share its snmp session and run sequentially",
+	)
+	var retries = flag.Int("retries", 0, "[number of retries] Failed snmp queries are retried this many times")
+	var retryBackoff = flag.Duration("retry-backoff", 0, "[delay before first retry] fe. 500ms. Delay is doubled on every next retry")
+	var deadline = flag.Duration("deadline", 0, "[total snmp time budget] fe. 8s. Components of jnx and cisco checks not collected in time are reported as unknown")
+	var laInterval = flag.String("la-interval", "all", "[loadavg alarm interval] (1|5|15|all)\n"+
+		"\tAll intervals are reported as perfdata, only selected interval(s) affect alarm level",
+	)
+	var cpuCountSrc = flag.String("cpu-count-source", "hrprocessor", "[source] Number of cpu cores used by loadavg levels (hrprocessor|hrdevice|laTable|<number>).\n"+
+		"\thrprocessor counts hrProcessorTable entries, hrdevice counts processors in hrDeviceTable,\n"+
+		"\tlaTable counts laIndex rows of UCD-SNMP-MIB laTable, number sets count explicitly",
+	)
+	var roundMode = flag.String("round-mode", "round", "[conversion of averaged values to integer before alarm comparison] (round|ceil|floor)")
+	var applianceOid = flag.String("appliance-oid", "", "[oid] Vendor cpu utilization % oid used by appliance check type")
+	var probeOrder = flag.String("probe-order", "", "[oid,oid,...] Candidate cpu utilization % oids tried in this order by firstof check type.\n"+
+		"\tFirst valid 0-100 value is used. hrProcessorLoad tries average of hrProcessorLoad walk",
+	)
+	var unit = flag.String("unit", "%", "[unit] Perfdata unit of -appliance-oid value (fe. %, c or empty for plain gauge).\n"+
+		"\tPercentage values are clamped to 0-100, other values are reported without min and max",
+	)
+	var rate = flag.String("rate", "", "[busy ticks oid,total ticks oid] Counters used by rate check type")
+	var withState = flag.Bool("with-state", false, "Using this parameter will annotate high cpu of jnx and cisco components which are booting or switching over")
+	var wlcProcess = flag.Bool("wlc-process", false, "Using this parameter cisco check will report top Catalyst 9800 wlc wireless control processes (fe. wncd, wncmgrd)\n"+
+		"\tof cpu which is above warning level. Alarm is calculated from cpmCPUTotal. Platforms without process table are reported without them",
+	)
+	var cpuProcess = flag.Bool("cpu-process", false, "Using this parameter will append top cpu consuming process to message of alarmed cisco cpu.\n"+
+		"\tUses cpmProcExtUtil5MinRev of CISCO-PROCESS-MIB. Platforms without process table are reported without it",
+	)
+	var stackNames = flag.Bool("stack-names", false, "Using this parameter cisco check will name cpus of Catalyst stack members by switch number (fe. 'switch2 1min').\n"+
+		"\tWalks entPhysicalContainedIn, entPhysicalClass and entPhysicalParentRelPos of ENTITY-MIB on every check",
+	)
+	var normalize = flag.Bool("normalize", false, "Using this parameter will add canonical 0-100 'cpu utilization' perfdata for every check type")
+	var alarmOnNorm = flag.Bool("alarm-on-normalized", false, "Using this parameter will calculate alarm level from canonical cpu utilization only. Implies -normalize")
+	var labelStyle = flag.String("label-style", "legacy", "[perfdata label style] (legacy|snake) legacy keeps labels of check type.\n"+
+		"\tsnake lower cases labels and replaces spaces and other separators with underscore,\n"+
+		"\tfe. 'cpu usage' -> cpu_usage, 'Routing Engine 0 util' -> routing_engine_0_util, '10.0.0.1 1min' -> 10_0_0_1_1min",
+	)
+	var strictPerf = flag.Bool("strict-perfdata", false, "Using this parameter will validate perfdata, drop dummy entries and quote only labels containing spaces")
+	var primaryOnly = flag.Bool("primary-only", false, "Using this parameter jnx alarm level is calculated only from routing engines in master jnxRedundancyState.\n"+
+		"\tBackup routing engines are reported as informational data",
+	)
+	var reAggregate = flag.String("re-aggregate", "", "[mode] Report canonical jnx cpu utilization as 'cpu utilization' perfdata (master|max).\n"+
+		"\tmaster uses util of routing engine in master jnxRedundancyState (busiest if not found), max util of busiest routing engine.\n"+
+		"\tPer routing engine perfdata is kept",
+	)
+	var reMode = flag.String("re-mode", "any", "[mode] Alarming of jnx routing engines (any|master|worst|average).\n"+
+		"\tany alarms every routing engine, master only routing engines in master jnxRedundancyState (as -primary-only),\n"+
+		"\tworst only busiest routing engine and average mean util of all routing engines reported as re_average perfdata",
+	)
+	var inclOffline = flag.Bool("include-offline", false, "Using this parameter will report jnx routing engines in unknown(1) and down(6) jnxOperatingState.\n"+
+		"\tBy default they are skipped as absent or offline",
+	)
+	var inclFabric = flag.Bool("include-fabric", false, "Using this parameter will report jnx switch fabric board (fabric/SIB/SFB) cpu as informational data")
+	var ha = flag.Bool("ha", false, "Using this parameter will report cpu of forti HA cluster members")
+	var unreachable = flag.String("unreachable", "critical", "[level of failed reachable check] (warning|critical)")
+	var expectComp = flag.Int("expect-components", 0, "[expected number of cpu components] jnx and cisco checks alarm when fewer components are found")
+	var expectLevel = flag.String("expect-level", "warning", "[level of missing components] (warning|critical)")
+	var schema = flag.Bool("schema-version", false, "Using this parameter will append perfdata schema version of check type as schema_version perfdata.\n"+
+		"\tsnake -label-style adds 1000 to schema version",
+	)
+	var timestamp = flag.Bool("timestamp", false, "Using this parameter will append check execution epoch as separate timestamp=<epoch> line to output")
+	var imbalance = flag.Int("imbalance-pct", 0, "[percentage points] jnx, cisco and forti -ha checks warn if cpu spread between busiest and least busy component exceeds this")
+	var tmpl = flag.String("template", "", "[go text/template of check message] fe. '{{.Load}}% on {{.CPUCount}} CPUs'\n"+
+		"\tFields: .Type, .Load (canonical cpu utilization), .CPUCount, .Components (map of component name to cpu utilization), .Messages",
+	)
+	var withTemp = flag.Bool("with-temp", false, "Using this parameter will report jnx routing engine temperature and note it on high cpu")
+	var withBuffer = flag.Bool("with-buffer", false, "Using this parameter will report jnx routing engine jnxOperatingBuffer utilization as informational perfdata")
+	var spu = flag.Bool("spu", false, "Using this parameter jnx check will alarm busiest SRX SPU using jnxJsSPUMonitoringCPUUsage.\n"+
+		"\tRouting engine cpu is reported as informational",
+	)
+	var crossCheck = flag.Int("cross-check", 0, "[percentage points] jnx check compares jnxOperatingCPU of only or primary routing engine with 100 - ssCpuIdle.\n"+
+		"\tBoth are reported as perfdata and check is unknown if they differ more than this. Skipped if ssCpuIdle is not available",
+	)
+	var warnOnZero = flag.Bool("warn-on-zero", false, "Using this parameter host, sysstats, jnx and cisco checks will warn if cpu utilization of all cpus is exactly 0%")
+	var strictWalk = flag.Bool("strict-walk", false, "Using this parameter host check reports unknown if hrProcessorLoad walk returns fewer cpus than hrDeviceTable lists")
+	var aggregate = flag.Bool("aggregate", false, "Using this parameter will report min, max and average cpu utilization of jnx, cisco and forti -ha components\n"+
+		"\tinstead of component details. Alarm level is calculated from max",
+	)
+	var baselineSigma = flag.Float64("baseline-sigma", 0, "[stddevs] warn if cpu utilization exceeds rolling baseline mean of host and check type by this many stddevs.\n"+
+		"\tBaseline of last 288 polls is kept in state file and used after 12 polls",
+	)
+	var stateDir = flag.String("state-dir", os.TempDir(), "[directory] State files of rate and baseline checks are kept here")
+	var unknownAs = flag.String("unknown-as", "unknown", "[level] Check level of missing cpu data (unknown|warn|crit)")
+	var suppressBoot = flag.Int("suppress-after-boot", 0, "[seconds] Cpu alarms of device booted less than this many seconds ago are capped to -suppress-level.\n"+
+		"\tsysUpTime is reported as perfdata. 0 disables",
+	)
+	var suppressLevel = flag.String("suppress-level", "warn", "[level] Highest cpu alarm level within -suppress-after-boot window (warn|ok)")
+	var deadband = flag.Int("deadband", 0, "[percentage points] Warning or critical state is kept until value drops this much below its level.\n"+
+		"\tLevels of last check are kept in state file of host and check type. First check and 0 work without hysteresis",
+	)
+	var precision = flag.Int("precision", 0, "[decimals] Number of decimals in cpu usage perfdata of host based and rate checks. Alarm levels are compared to rounded value")
+	var intervalMode = flag.String("interval-mode", "each", "[mode] Alarming of cisco and moxasw intervals (each|worst).\n"+
+		"\tWith worst one message with level of worst interval is reported per cpu. All intervals are reported as perfdata",
+	)
+	var sampleTwice = flag.Bool("sample-twice", false, "Using this parameter rcsw, moxasw and host checks take two samples -sample-interval apart and report them merged by -sample-mode.\n"+
+		"\tCheck run time grows by sample interval",
+	)
+	var sampleInterval = flag.Duration("sample-interval", 5*time.Second, "[duration] Delay between samples of -sample-twice")
+	var sampleMode = flag.String("sample-mode", "avg", "[mode] Merge of -sample-twice samples (avg|max)")
+	var minInterval = flag.Duration("min-interval", 0, "[duration] Devices are not polled more often than this. Last result is reported with note when called too soon.\n"+
+		"\tLast result is kept in state file. Independent of rate and baseline state",
+	)
+	var graphite = flag.Bool("graphite", false, "Using this parameter will print out performance data as graphite plaintext lines\n"+
+		"\t(<prefix>.<host>.<metric> <value> <timestamp>) instead of plugin output. Exit code is not changed",
+	)
+	var graphitePrefix = flag.String("graphite-prefix", "cpu", "[prefix] Metric namespace of -graphite output")
+	var compThresholds = flag.String("component-thresholds", "", "[name or index=warning/critical,...] Levels of jnx, cisco, iosxr and cmts components overriding -w and -c.\n"+
+		"\tComponents are matched by name (fe. \"Routing Engine 1=90/95\") or snmp index. Cisco levels must be integers",
+	)
+	var alarmRegex = flag.String("alarm-regex", "", "[regexp] Only jnx, cisco, iosxr and cmts components with display name (fe. \"Routing Engine 0\") matching this are alarmed.\n"+
+		"\tPerfdata of all components is reported, other components are informational",
+	)
+	var verify = flag.Bool("verify", false, "Using this parameter will check sysObjectID enterprise of device against vendor specific check type\n"+
+		"\tand report unknown with check type hint on mismatch",
+	)
+	var hostFile = flag.String("host-file", "", "[file] Hosts checked in addition to -H with same parameters. Every line is\n"+
+		"\t\"<host> [<username|community> [<auth pass phrase> [<privacy pass phrase>]]]\" overriding -u, -A and -X of host",
+	)
+	var thresholdsFile = flag.String("thresholds-file", "", "[file] Levels by host and optionally check type overriding check type defaults.\n"+
+		"\tEvery line is \"<host> [<type>] <warning>/<critical>\". Explicitly set -w and -c are preferred",
+	)
+	var probeAll = flag.Bool("probe-all", false, "Using this parameter will try every check type on first host and print out table of check type, result and cpu utilization\n"+
+		"\twithout alarming. Exit code is OK. Total probe time is bounded by -deadline, 30s if not set",
+	)
+	var dryRun = flag.Bool("dry-run", false, "Using this parameter will print out oids and alarm levels of check type and exit without snmp queries")
+	var oids = flag.Bool("oids", false, "Using this parameter will print out base oids queried by check type and exit without snmp queries. Host is not required.\n"+
+		"\tOids relative to sysObjectID of device (moxasw) are shown by -dry-run only",
+	)
+	var format = flag.String("format", "nagios", "[output format] (nagios|raw).\n"+
+		"\tnagios removes trailing spaces and empty lines and ends output with exactly one newline. raw prints out plugin output as is",
+	)
+	var config = flag.String("config", "", "[file] Options are read from this file. Every line is \"<option>=<value>\" where option is flag name\n"+
+		"\twithout dash (fe. t=jnx). Command line options are preferred",
 	)
 	var dbg = flag.Bool("d", false, "Using this parameter will print out debug info")
 	var ver = flag.Bool("v", false, "Using this parameter will display the version number and exit")
 
 	flag.Parse()
 
+	// Check execution time
+	start := time.Now()
+
+	// Options of config file are used unless set in command line
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if *config != "" {
+		err := applyConfig(*config, set)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(3)
+		}
+		flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	}
+
+	// Use default levels of check type unless levels are set explicitly
+	if w, c, ok := cpu.DefaultLevels(*ctype); ok {
+		if !set["w"] {
+			*warn = w
+		}
+		if !set["c"] {
+			*crit = c
+		}
+	}
+
 	// Initialize new check object
 	check := icingahelper.NewCheck("CPU")
 
+	// Print out plugin output formatted by -format and exit
+	exit := func(out string, code int) {
+		fmt.Print(formatOutput(out, *format))
+		os.Exit(code)
+	}
+
+	// Exit if output format is not valid
+	if *format != "nagios" && *format != "raw" {
+		fmt.Println("not valid output format - " + *format)
+		os.Exit(check.RetVal())
+	}
+
 	// Show version
 	if *ver {
 		fmt.Println("plugin version " + Version)
 		os.Exit(check.RetVal())
 	}
 
-	// Exit if no host submitted
-	if net.ParseIP(*host) == nil {
-		fmt.Println("valid host ip is required")
-		os.Exit(check.RetVal())
+	// Exit if source address is not usable
+	if *sourceAddr != "" {
+		if net.ParseIP(*sourceAddr) == nil {
+			exit("valid source ip is required", check.RetVal())
+		}
+		c, err := net.ListenPacket("udp", net.JoinHostPort(*sourceAddr, "0"))
+		if err != nil {
+			exit(fmt.Sprintf("source address error: %v", err), check.RetVal())
+		}
+		c.Close()
+	}
+
+	// Exit if engine ID is not valid hex of 5-32 octets
+	var engine []byte
+	if *engineID != "" {
+		var err error
+		engine, err = hex.DecodeString(strings.TrimPrefix(strings.ToLower(*engineID), "0x"))
+		if err != nil || len(engine) < 5 || len(engine) > 32 {
+			exit("valid engine id of 5-32 octets in hex is required", check.RetVal())
+		}
+	}
+
+	// Exit if auth probe combinations are not valid. Explicit -a and -x pin protocols.
+	var combos [][2]string
+	if *authProbe != "" && !(set["a"] && set["x"]) {
+		for _, c := range strings.Split(*authProbe, ",") {
+			p := strings.Split(strings.TrimSpace(c), "/")
+			if len(p) != 2 || p[0] == "" || p[1] == "" {
+				exit("auth probe combinations must be in form <auth>/<priv>", check.RetVal())
+			}
+			if (set["a"] && p[0] != *snmpProt) || (set["x"] && p[1] != *snmpPrivProt) {
+				continue
+			}
+			combos = append(combos, [2]string{p[0], p[1]})
+		}
+		if len(combos) == 0 {
+			exit("no auth probe combination matches -a or -x", check.RetVal())
+		}
+	}
+
+	// Exit if timeout status is not valid
+	if _, ok := timeoutLevels[*timeoutStatus]; !ok {
+		exit("timeout status must be unknown or critical", check.RetVal())
+	}
+
+	// Exit if concurrency is not valid
+	if *concurrency < 1 {
+		exit("concurrency must be at least 1", check.RetVal())
+	}
+
+	// Exit if thresholds file is not usable
+	var tfLevels fileLevels
+	if *thresholdsFile != "" {
+		var err error
+		tfLevels, err = readThresholds(*thresholdsFile)
+		if err != nil {
+			exit(err.Error(), check.RetVal())
+		}
 	}
 
 	// Exit if no type submitted
-	if *ctype == "" {
-		fmt.Println("check type required")
-		os.Exit(check.RetVal())
+	if *ctype == "" && !*probeAll {
+		exit("check type required", check.RetVal())
 	}
 
-	// Session variables
-	session := snmphelper.Session{
-		Host:     *host,
-		Ver:      *snmpVer,
-		User:     *snmpUser,
-		Prot:     *snmpProt,
-		Pass:     *snmpPass,
-		Slevel:   *snmpSlevel,
-		PrivProt: *snmpPrivProt,
-		PrivPass: *snmpPrivPass,
+	// Returns CPU load object for session
+	newLoad := func(sess *snmphelper.Session, prefix string) *cpu.Load {
+		w, c := *warn, *crit
+		if v, ok := tfLevels.levels(sess.Host, *ctype); ok {
+			if !set["w"] {
+				w = v[0]
+			}
+			if !set["c"] {
+				c = v[1]
+			}
+		}
+
+		return &cpu.Load{
+			Check:             check,
+			Sess:              sess,
+			Warn:              w,
+			Crit:              c,
+			Ctype:             *ctype,
+			MoxaSuffix:        *moxaSuffix,
+			Rate:              *rate,
+			ApplianceOid:      *applianceOid,
+			ProbeOrder:        *probeOrder,
+			Unit:              *unit,
+			UnitSet:           set["unit"],
+			Prefix:            prefix,
+			LaInterval:        *laInterval,
+			CPUCountSource:    *cpuCountSrc,
+			Retries:           *retries,
+			MaxOids:           *maxOids,
+			RetryEmpty:        *retryEmpty,
+			RetryBackoff:      *retryBackoff,
+			WithState:         *withState,
+			CPUProcess:        *cpuProcess,
+			WlcProcess:        *wlcProcess,
+			StackNames:        *stackNames,
+			Normalize:         *normalize,
+			AlarmOnNormalized: *alarmOnNorm,
+			StrictPerf:        *strictPerf,
+			Schema:            *schema,
+			LabelStyle:        *labelStyle,
+			PrimaryOnly:       *primaryOnly,
+			ReMode:            *reMode,
+			ReAggregate:       *reAggregate,
+			IncludeOffline:    *inclOffline,
+			IncludeFabric:     *inclFabric,
+			Deadline:          *deadline,
+			RoundMode:         *roundMode,
+			SampleTwice:       *sampleTwice,
+			SampleInterval:    *sampleInterval,
+			SampleMode:        *sampleMode,
+			IntervalMode:      *intervalMode,
+			Ha:                *ha,
+			Unreachable:       *unreachable,
+			ExpectComponents:  *expectComp,
+			ExpectLevel:       *expectLevel,
+			ImbalancePct:      *imbalance,
+			Template:          *tmpl,
+			WithTemp:          *withTemp,
+			WithBuffer:        *withBuffer,
+			Spu:               *spu,
+			CrossCheck:        *crossCheck,
+			WarnOnZero:        *warnOnZero,
+			StrictWalk:        *strictWalk,
+			Aggregate:         *aggregate,
+			BaselineSigma:     *baselineSigma,
+			StateDir:          *stateDir,
+			CompThresholds:    *compThresholds,
+			AlarmRegex:        *alarmRegex,
+			Verify:            *verify,
+			UnknownAs:         *unknownAs,
+			Precision:         *precision,
+			SuppressAfterBoot: *suppressBoot,
+			SuppressLevel:     *suppressLevel,
+			Deadband:          *deadband,
+			Debug:             *dbg,
+		}
 	}
 
-	// Initialize session
-	sess, err := session.New()
-	if err != nil {
-		fmt.Printf("snmp error: %v\n", err)
-		os.Exit(check.RetVal())
+	// Show oids of check type without snmp traffic
+	if *oids {
+		err := newLoad(&snmphelper.Session{}, "").Oids()
+		if err != nil {
+			exit(err.Error(), check.RetVal())
+		}
+		os.Exit(0)
 	}
 
-	// Get CPU load
-	load := cpu.Load{
-		Check: check,
-		Sess:  sess,
-		Warn:  *warn,
-		Crit:  *crit,
-		Ctype: *ctype,
-		Debug: *dbg,
+	// Exit if no valid host submitted
+	var hosts []string
+	if *host != "" || *hostFile == "" {
+		hosts = strings.Split(*host, ",")
+	}
+	for _, h := range hosts {
+		if net.ParseIP(h) == nil {
+			exit("valid host ip is required", check.RetVal())
+		}
 	}
 
-	err = load.Get()
-	if err != nil {
-		fmt.Println(err)
+	// Exit if host file is not usable
+	var creds hostCreds
+	if *hostFile != "" {
+		fh, fc, err := readHosts(*hostFile)
+		if err != nil {
+			exit(err.Error(), check.RetVal())
+		}
+		hosts, creds = append(hosts, fh...), fc
+	}
+
+	// Show check plan without snmp traffic
+	if *dryRun {
+		err := newLoad(&snmphelper.Session{Host: hosts[0]}, "").DryRun()
+		if err != nil {
+			exit(err.Error(), check.RetVal())
+		}
+		os.Exit(0)
+	}
+
+	// Returns initialized snmp session of host and note of used privacy protocol fallback
+	connect := func(h, prefix string) (*snmphelper.Session, string, error) {
+		// Session variables
+		session := snmphelper.Session{
+			Host:     h,
+			Ver:      *snmpVer,
+			User:     *snmpUser,
+			Prot:     *snmpProt,
+			Pass:     *snmpPass,
+			Slevel:   *snmpSlevel,
+			PrivProt: *snmpPrivProt,
+			PrivPass: *snmpPrivPass,
+		}
+		if c, ok := creds[h]; ok {
+			for i, p := range []*string{&session.User, &session.Pass, &session.PrivPass} {
+				if c[i] != "" {
+					*p = c[i]
+				}
+			}
+		}
+
+		// Initialize session
+		newSession := func(s snmphelper.Session) (*snmphelper.Session, error) {
+			sess, err := s.New()
+			if err != nil {
+				return nil, fmt.Errorf("snmp error: %v", err)
+			}
+			if *sourceAddr != "" {
+				sess.Snmp.LocalAddr = net.JoinHostPort(*sourceAddr, "0")
+			}
+			if usm, ok := sess.Snmp.SecurityParameters.(*gosnmp.UsmSecurityParameters); ok && engine != nil {
+				usm.AuthoritativeEngineID = string(engine)
+			}
+			return sess, nil
+		}
+
+		// Try auth probe combinations starting from last working one of host
+		if len(combos) > 0 && session.Ver == 3 {
+			var last [2]string
+			state.Load(*stateDir, h, "snmp", "auth", &last)
+			var order [][2]string
+			for _, c := range combos {
+				if c == last {
+					order = append([][2]string{c}, order...)
+				} else {
+					order = append(order, c)
+				}
+			}
+
+			var err error
+			for _, c := range order {
+				session.Prot, session.PrivProt = c[0], c[1]
+				sess, serr := newSession(session)
+				if serr != nil {
+					return nil, "", serr
+				}
+				if _, err = sess.Get([]string{".1.3.6.1.2.1.1.3.0"}); err != nil {
+					continue
+				}
+				// DEBUG
+				if *dbg {
+					fmt.Printf("%s auth probe: %s/%s works\n", h, c[0], c[1])
+				}
+				if c != last {
+					if serr := state.Save(*stateDir, h, "snmp", "auth", c); serr != nil && *dbg {
+						fmt.Println(serr)
+					}
+				}
+				return sess, "", nil
+			}
+			return nil, "", fmt.Errorf("snmp error: no auth probe combination works: %v", err)
+		}
+
+		sess, err := newSession(session)
+		if err != nil {
+			return nil, "", err
+		}
+
+		// Probe session using sysUpTime and retry with alternative privacy protocol on failure
+		if alt, ok := privVariants[session.PrivProt]; ok && *privFallback && session.Ver == 3 {
+			if _, err := sess.Get([]string{".1.3.6.1.2.1.1.3.0"}); err != nil {
+				session.PrivProt = alt
+				as, aerr := newSession(session)
+				if aerr != nil {
+					return nil, "", aerr
+				}
+				if _, aerr := as.Get([]string{".1.3.6.1.2.1.1.3.0"}); aerr != nil {
+					return nil, "", fmt.Errorf("snmp error: %v", err)
+				}
+				sess = as
+				msg := "privacy protocol " + alt + " used"
+				if prefix != "" {
+					msg = prefix + ": " + msg
+				}
+				return sess, msg, nil
+			}
+		}
+
+		return sess, "", nil
+	}
+
+	// Try every check type and report which of them return cpu data
+	if *probeAll {
+		sess, _, err := connect(hosts[0], "")
+		if err != nil {
+			exit(err.Error(), check.RetVal())
+		}
+
+		budget := *deadline
+		if budget <= 0 {
+			budget = 30 * time.Second
+		}
+		end := start.Add(budget)
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "TYPE\tRESULT\tVALUE")
+		for _, t := range cpu.Types() {
+			// Reachability, counter rate and candidate oid checks do not discover anything
+			if t == "reachable" || t == "rate" || t == "firstof" {
+				continue
+			}
+
+			left := time.Until(end)
+			if left <= 0 {
+				fmt.Fprintf(tw, "%s\tnot probed, deadline reached\t-\n", t)
+				continue
+			}
+
+			l := newLoad(sess, "")
+			l.Check = icingahelper.NewCheck("CPU")
+			l.Ctype = t
+			l.Warn, l.Crit = "85", "95"
+			if w, c, ok := cpu.DefaultLevels(t); ok {
+				l.Warn, l.Crit = w, c
+			}
+			l.Deadline = left
+			l.BaselineSigma = 0
+			l.Template = ""
+
+			result, value := "ok", "-"
+			if err := l.Get(); err != nil {
+				result = "failed"
+				if *dbg {
+					result += ": " + err.Error()
+				}
+			} else if v, ok := l.Utilization(); ok {
+				value = fmt.Sprintf("%d%%", v)
+			} else if l.Check.RetVal() == 3 {
+				result = "no cpu data"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", t, result, value)
+		}
+		tw.Flush()
+		os.Exit(0)
+	}
+
+	// Get CPU load of one host. Buffered output is reported to check by Flush.
+	poll := func(h, prefix string, buffered bool) hostResult {
+		sess, note, err := connect(h, prefix)
+		if err != nil {
+			return hostResult{err: err}
+		}
+
+		l := newLoad(sess, prefix)
+		if buffered {
+			l.Check = icingahelper.NewCheck("CPU")
+			l.Buffered = true
+		} else if note != "" {
+			check.AddMsg(0, note, "")
+			note = ""
+		}
+
+		return hostResult{load: l, note: note, err: l.Get()}
+	}
+
+	// Report last result if device was polled too recently
+	if *minInterval > 0 {
+		var last lastResult
+		if state.Load(*stateDir, stateHost(hosts), *ctype, "last", &last) {
+			age := start.Sub(time.Unix(last.Time, 0))
+			if age >= 0 && age < *minInterval {
+				out := last.Output
+				if !strings.HasSuffix(out, "\n") {
+					out += "\n"
+				}
+				out += fmt.Sprintf("last result from %v ago, min interval %v\n", age.Round(time.Second), *minInterval)
+				exit(out, last.RetVal)
+			}
+		}
+	}
+
+	results := make([]hostResult, len(hosts))
+	if len(hosts) == 1 {
+		results[0] = poll(hosts[0], "", false)
+	} else {
+		// At most -concurrency hosts are polled at a time
+		sem := make(chan struct{}, *concurrency)
+		var wg sync.WaitGroup
+		for i, h := range hosts {
+			wg.Add(1)
+			go func(i int, h string) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				results[i] = poll(h, h, true)
+			}(i, h)
+		}
+		wg.Wait()
+
+		// Output is reported in order of hosts. Failing host is reported as
+		// unknown without failing the whole check.
+		var failed []int
+		for i, r := range results {
+			if r.note != "" {
+				check.AddMsg(0, r.note, "")
+			}
+			if r.load != nil {
+				r.load.Flush(check)
+			}
+			if r.err != nil {
+				level := 3
+				if timedOut(r.err) {
+					level = timeoutLevels[*timeoutStatus]
+				}
+				failed = append(failed, level)
+				check.AddMsg(level, fmt.Sprintf("%s: %v", hosts[i], r.err), "")
+			}
+		}
+
+		// Failing hosts raise check status after all hosts are flushed, so
+		// a check with unknown host is never summarised as OK or warning
+		for _, level := range failed {
+			c := check.RetVal()
+			switch {
+			case level == 2 && c != 2:
+				check.SetRetVal(2)
+			case level == 3 && c < 2:
+				check.SetRetVal(3)
+			case level == 1 && c == 0:
+				check.SetRetVal(1)
+			}
+		}
+	}
+
+	// Failed single host exits with -timeout-status level on snmp timeout
+	if len(hosts) == 1 && results[0].err != nil {
+		code := check.RetVal()
+		if timedOut(results[0].err) {
+			code = timeoutLevels[*timeoutStatus]
+		}
+		if *graphite {
+			fmt.Fprintln(os.Stderr, results[0].err)
+			os.Exit(code)
+		}
+		exit(results[0].err.Error(), code)
+	}
+
+	if *graphite {
+		for i, r := range results {
+			if r.err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", hosts[i], r.err)
+			}
+			if r.load == nil {
+				continue
+			}
+			for _, m := range r.load.Metrics() {
+				fmt.Printf("%s.%s.%s %s %d\n", *graphitePrefix, graphiteName(hosts[i]), graphiteName(m.Label), m.Value, start.Unix())
+			}
+		}
 		os.Exit(check.RetVal())
 	}
 
-	fmt.Print(check.FinalMsg())
-	os.Exit(check.RetVal())
+	out := check.FinalMsg()
+	if *timestamp {
+		if !strings.HasSuffix(out, "\n") {
+			out += "\n"
+		}
+		out += fmt.Sprintf("timestamp=%d\n", start.Unix())
+	}
+
+	if *minInterval > 0 {
+		err := state.Save(*stateDir, stateHost(hosts), *ctype, "last", lastResult{Time: start.Unix(), Output: out, RetVal: check.RetVal()})
+		if err != nil && *dbg {
+			fmt.Println(err)
+		}
+	}
+
+	exit(out, check.RetVal())
 }
@@ -0,0 +1,70 @@
+// package state implements persistent check state shared between polls
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Returns state file path of host, check type and metric in dir.
+// Empty dir means system temp dir.
+func File(dir, host, ctype, metric string) string {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	r := strings.NewReplacer("/", "_", string(os.PathSeparator), "_")
+	name := "check-gosnmp-cpu_" + r.Replace(host) + "_" + r.Replace(ctype) + "_" + r.Replace(metric) + ".json"
+
+	return filepath.Join(dir, name)
+}
+
+// Load state of host, check type and metric into v.
+// Returns false if state is missing or not readable.
+func Load(dir, host, ctype, metric string, v interface{}) bool {
+	b, err := ioutil.ReadFile(File(dir, host, ctype, metric))
+	if err != nil {
+		return false
+	}
+
+	return json.Unmarshal(b, v) == nil
+}
+
+// Save state of host, check type and metric from v. State file is replaced
+// atomically so concurrent checks never read partially written state.
+func Save(dir, host, ctype, metric string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("state error: %v", err)
+	}
+
+	file := File(dir, host, ctype, metric)
+	err = os.MkdirAll(filepath.Dir(file), 0700)
+	if err != nil {
+		return fmt.Errorf("state error: %v", err)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".tmp")
+	if err != nil {
+		return fmt.Errorf("state error: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(b)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("state error: %v", err)
+	}
+
+	err = os.Rename(tmp.Name(), file)
+	if err != nil {
+		return fmt.Errorf("state error: %v", err)
+	}
+
+	return nil
+}
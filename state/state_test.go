@@ -0,0 +1,51 @@
+package state
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveLoad(t *testing.T) {
+	type sample struct {
+		Time  int64
+		Value int64
+	}
+
+	dir := t.TempDir()
+	in := sample{Time: 1600000000, Value: 42}
+	if err := Save(dir, "10.0.0.1", "cisco", "usage", in); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	var out sample
+	if !Load(dir, "10.0.0.1", "cisco", "usage", &out) {
+		t.Fatal("Load: state missing after Save")
+	}
+	if out != in {
+		t.Errorf("Load: got %+v, want %+v", out, in)
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	var out map[string]int64
+	if Load(t.TempDir(), "10.0.0.1", "cisco", "usage", &out) {
+		t.Error("Load: got true for missing state")
+	}
+}
+
+func TestFileSanitise(t *testing.T) {
+	dir := t.TempDir()
+	file := File(dir, "host/a", "cisco", "cpu/1 usage")
+
+	if filepath.Dir(file) != dir {
+		t.Errorf("File: %q is not in %q", file, dir)
+	}
+	name := filepath.Base(file)
+	if strings.Contains(name, "/") {
+		t.Errorf("File: %q contains /", name)
+	}
+	if want := "check-gosnmp-cpu_host_a_cisco_cpu_1 usage.json"; name != want {
+		t.Errorf("File: got %q, want %q", name, want)
+	}
+}